@@ -0,0 +1,58 @@
+package configvalidate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/config"
+	"github.com/wakatime/wakatime-cli/pkg/exitcode"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Run validates the wakatime config file and prints all found problems.
+// Exits with exitcode.ErrConfigFileParse, if problems were found.
+func Run(v *viper.Viper) {
+	output, err := Validate(v)
+	if err != nil {
+		var errparse config.ErrFileParse
+		if errors.As(err, &errparse) {
+			fmt.Println(output)
+			os.Exit(exitcode.ErrConfigFileParse)
+		}
+
+		jww.ERROR.Printf("err: %s", err)
+		os.Exit(exitcode.ErrDefault)
+	}
+
+	fmt.Println(output)
+	os.Exit(exitcode.Success)
+}
+
+// Validate validates the wakatime config file and returns a human readable
+// report. Returns config.ErrFileParse, if problems were found.
+func Validate(v *viper.Viper) (string, error) {
+	fp, err := config.FilePath(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to get config file path: %s", err)
+	}
+
+	problems, err := config.Validate(fp)
+	if err != nil {
+		return "", err
+	}
+
+	if len(problems) == 0 {
+		return fmt.Sprintf("no problems found in %s", fp), nil
+	}
+
+	lines := []string{fmt.Sprintf("%d problem(s) found in %s:", len(problems), fp)}
+	for _, p := range problems {
+		lines = append(lines, p.String())
+	}
+
+	return strings.Join(lines, "\n"), config.ErrFileParse(fmt.Sprintf("%d problem(s) found in config file", len(problems)))
+}
@@ -0,0 +1,61 @@
+package configvalidate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/cmd/legacy/configvalidate"
+	"github.com/wakatime/wakatime-cli/pkg/config"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime.cfg")
+
+	err = ioutil.WriteFile(fp, []byte("[settings]\napi_key = 00000000-0000-4000-8000-000000000000\n"), 0600)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("config", fp)
+
+	output, err := configvalidate.Validate(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "no problems found in "+fp, output)
+}
+
+func TestValidate_Problems(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime.cfg")
+
+	err = ioutil.WriteFile(fp, []byte("[settings]\nexlude = ^/tmp/\napikey = invalid\n"), 0600)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("config", fp)
+
+	output, err := configvalidate.Validate(v)
+
+	var errparse config.ErrFileParse
+
+	assert.True(t, errors.As(err, &errparse))
+	assert.Equal(t, "3 problem(s) found in "+fp+":\n"+
+		"line 2: [settings] exlude: unknown key\n"+
+		`line 3: [settings] apikey: deprecated alias, use "api_key" instead`+"\n"+
+		"line 3: [settings] apikey: malformed api key", output)
+}
@@ -1,21 +1,50 @@
 package heartbeat
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/config"
 	"github.com/wakatime/wakatime-cli/pkg/exitcode"
 	"github.com/wakatime/wakatime-cli/pkg/filestats"
 	"github.com/wakatime/wakatime-cli/pkg/filter"
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/offline"
+	"github.com/wakatime/wakatime-cli/pkg/pause"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+	"github.com/wakatime/wakatime-cli/pkg/route"
+	"github.com/wakatime/wakatime-cli/pkg/secret"
 
+	_ "github.com/mattn/go-sqlite3" // sqlite driver for the offline queue
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 )
 
+// offlineSyncLimit is the max number of queued heartbeats sent along with new
+// ones. The bulk endpoint accepts up to 25 heartbeats per request.
+const offlineSyncLimit = 24
+
+// RunDryRun executes the heartbeat command without sending heartbeats and
+// prints what would have been sent.
+func RunDryRun(v *viper.Viper) {
+	output, err := DryRun(v)
+	if err != nil {
+		jww.CRITICAL.Printf("failed to process heartbeat: %s", err)
+		os.Exit(exitcode.ErrDefault)
+	}
+
+	fmt.Println(output)
+	os.Exit(exitcode.Success)
+}
+
 // Run executes the heartbeat command.
 func Run(v *viper.Viper) {
 	err := SendHeartbeat(v)
@@ -35,6 +64,12 @@ func Run(v *viper.Viper) {
 			os.Exit(exitcode.ErrAPI)
 		}
 
+		var errparse config.ErrFileParse
+		if errors.As(err, &errparse) {
+			jww.CRITICAL.Printf("failed to send heartbeat: %s", err)
+			os.Exit(exitcode.ErrConfigFileParse)
+		}
+
 		jww.CRITICAL.Printf("failed to send heartbeat: %s", err)
 		os.Exit(exitcode.ErrDefault)
 	}
@@ -49,11 +84,258 @@ func SendHeartbeat(v *viper.Viper) error {
 		return fmt.Errorf("failed to load command parameters: %w", err)
 	}
 
+	newAPIDestination := func(apiURL, apiKey string) (route.Destination, error) {
+		return newDestination(params, apiURL, apiKey)
+	}
+
+	sender, err := newSender(params, nil, newAPIDestination, newAPIDestination)
+	if err != nil {
+		return err
+	}
+
+	handle := heartbeat.NewHandle(sender, handleOptions(params, nil, false)...)
+
+	_, err = handle([]heartbeat.Heartbeat{newHeartbeat(params)})
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeats via api client: %w", err)
+	}
+
+	return nil
+}
+
+// DryRun runs the heartbeat processing pipeline without sending anything to
+// the wakatime api. Returns the heartbeats, which would have been sent, in
+// JSON format and a trace of the decisions of all pipeline stages.
+func DryRun(v *viper.Viper) (string, error) {
+	params, err := LoadParams(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to load command parameters: %w", err)
+	}
+
+	trace := &heartbeat.Trace{}
+	dryRun := &dryRunSender{}
+
+	newDryRunDestination := func(apiURL, apiKey string) (route.Destination, error) {
+		return route.Destination{
+			Name:   destinationName(apiURL, apiKey),
+			Handle: dryRun.Send,
+		}, nil
+	}
+
+	// mirrors receive the same heartbeats as the main destination, so they are not collected twice
+	newDryRunMirror := func(apiURL, apiKey string) (route.Destination, error) {
+		return route.Destination{
+			Name:   destinationName(apiURL, apiKey),
+			Handle: (&dryRunSender{}).Send,
+		}, nil
+	}
+
+	sender, err := newSender(params, trace, newDryRunDestination, newDryRunMirror)
+	if err != nil {
+		return "", err
+	}
+
+	handle := heartbeat.NewHandle(sender, handleOptions(params, trace, true)...)
+
+	_, err = handle([]heartbeat.Heartbeat{newHeartbeat(params)})
+	if err != nil {
+		return "", fmt.Errorf("failed to process heartbeats: %w", err)
+	}
+
+	var lines []string
+
+	if len(dryRun.heartbeats) == 0 {
+		lines = append(lines, "no heartbeat left to send")
+	} else {
+		data, err := json.MarshalIndent(dryRun.heartbeats, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to json encode heartbeats: %s", err)
+		}
+
+		lines = append(lines, string(data))
+	}
+
+	if len(trace.Entries) > 0 {
+		lines = append(lines, "", "trace:", trace.String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// dryRunSender collects heartbeats instead of sending them.
+type dryRunSender struct {
+	heartbeats []heartbeat.Heartbeat
+}
+
+// Send collects heartbeats and returns a result for each of them.
+func (s *dryRunSender) Send(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+	s.heartbeats = append(s.heartbeats, hh...)
+
+	results := make([]heartbeat.Result, len(hh))
+	for i, h := range hh {
+		results[i] = heartbeat.Result{Heartbeat: h}
+	}
+
+	return results, nil
+}
+
+// destinationFunc creates a destination sending heartbeats to apiURL with apiKey.
+type destinationFunc func(apiURL, apiKey string) (route.Destination, error)
+
+// newSender creates a sender routing heartbeats to the main and the
+// [api_routes] destinations created by newDestination. Heartbeats sent to the
+// main destination are sent to the settings.api_mirrors destinations created
+// by newMirror as well. Heartbeats outside of the schedule, re-tagged with
+// schedule.outside_api_key, are sent to the main api url with their own
+// destination and offline queue, as queued heartbeats do not keep their api key.
+func newSender(
+	params Params,
+	trace *heartbeat.Trace,
+	newDestination, newMirror destinationFunc,
+) (heartbeat.Sender, error) {
+	defaultDestination, err := newDestination(params.APIUrl, params.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.APIMirrors) > 0 {
+		fanOut := route.FanOut{
+			Destinations: []route.Destination{defaultDestination},
+			Trace:        trace,
+		}
+
+		for _, m := range params.APIMirrors {
+			mirror, err := newMirror(m.APIUrl, m.APIKey)
+			if err != nil {
+				return nil, err
+			}
+
+			fanOut.Destinations = append(fanOut.Destinations, mirror)
+		}
+
+		defaultDestination = route.Destination{
+			Name:   fmt.Sprintf("%s and %d mirror(s)", defaultDestination.Name, len(params.APIMirrors)),
+			Handle: fanOut.Send,
+		}
+	}
+
+	// routes to the main api key and url are mirrored as well
+	destinations := map[string]route.Destination{
+		destinationName(params.APIUrl, params.APIKey): defaultDestination,
+	}
+
+	routes := make([]route.Route, len(params.APIRoutes))
+
+	for i, r := range params.APIRoutes {
+		destination, ok := destinations[destinationName(r.APIUrl, r.APIKey)]
+		if !ok {
+			destination, err = newDestination(r.APIUrl, r.APIKey)
+			if err != nil {
+				return nil, err
+			}
+
+			destinations[destinationName(r.APIUrl, r.APIKey)] = destination
+		}
+
+		routes[i] = route.Route{
+			Destination: destination,
+			Pattern:     r.Pattern,
+			Rule:        r.Rule,
+		}
+	}
+
+	sender := route.Sender{
+		Default: defaultDestination,
+		Routes:  routes,
+		Trace:   trace,
+	}
+
+	if params.Filter.Schedule != nil && params.Filter.Schedule.OutsideAPIKey != "" {
+		apiKey := params.Filter.Schedule.OutsideAPIKey
+
+		destination, ok := destinations[destinationName(params.APIUrl, apiKey)]
+		if !ok {
+			destination, err = newDestination(params.APIUrl, apiKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sender.APIKeys = map[string]route.Destination{
+			apiKey: destination,
+		}
+	}
+
+	return sender, nil
+}
+
+// newDestination creates a destination sending heartbeats via an api client.
+// Heartbeats failing to be sent are queued in an offline queue per destination.
+// If offline encryption is enabled, but the key cannot be loaded, heartbeats
+// are not queued at all.
+func newDestination(params Params, apiURL, apiKey string) (route.Destination, error) {
+	c, err := newClient(params, apiURL, apiKey)
+	if err != nil {
+		return route.Destination{}, err
+	}
+
+	name := destinationName(apiURL, apiKey)
+	destination := route.Destination{
+		Name:   name,
+		Handle: c.Send,
+	}
+
+	fp, err := offlineQueueFilepath(params, apiURL, apiKey)
+	if err != nil {
+		jww.WARN.Printf("failed to get offline queue file path for %s: %s", name, err)
+		return destination, nil
+	}
+
+	var queueOpts []offline.Option
+
+	if params.Offline.Encryption {
+		c, err := newOfflineCipher(params.Offline, apiKey)
+		if err != nil {
+			jww.WARN.Printf("failed to set up offline queue encryption for %s: %s", name, err)
+			return destination, nil
+		}
+
+		queueOpts = append(queueOpts, offline.WithEncryption(c))
+	}
+
+	withQueue, err := offline.WithQueue(fp, offlineSyncLimit, queueOpts...)
+	if err != nil {
+		jww.WARN.Printf("failed to set up offline queue for %s: %s", name, err)
+		return destination, nil
+	}
+
+	destination.Handle = heartbeat.NewHandle(c, withQueue)
+
+	return destination, nil
+}
+
+// newOfflineCipher creates the cipher to encrypt an offline queue with. The key
+// is read from the key file, which is created if missing, or derived from apiKey.
+func newOfflineCipher(params OfflineParams, apiKey string) (*offline.Cipher, error) {
+	if params.KeyFilepath == "" {
+		return offline.NewCipher(offline.KeyFromAPIKey(apiKey))
+	}
+
+	key, err := offline.LoadKeyFile(params.KeyFilepath)
+	if err != nil {
+		return nil, err
+	}
+
+	return offline.NewCipher(key)
+}
+
+// newClient creates an api client for apiURL authenticated with apiKey.
+func newClient(params Params, apiURL, apiKey string) (*api.Client, error) {
 	withAuth, err := api.WithAuth(api.BasicAuth{
-		Secret: params.APIKey,
+		Secret: apiKey,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to set up auth option on api client: %w", err)
+		return nil, fmt.Errorf("failed to set up auth option on api client: %w", err)
 	}
 
 	clientOpts := []api.Option{
@@ -68,7 +350,7 @@ func SendHeartbeat(v *viper.Viper) error {
 	if params.Network.ProxyURL != "" {
 		withProxy, err := api.WithProxy(params.Network.ProxyURL)
 		if err != nil {
-			return fmt.Errorf("failed to set up proxy option on api client: %w", err)
+			return nil, fmt.Errorf("failed to set up proxy option on api client: %w", err)
 		}
 
 		clientOpts = append(clientOpts, withProxy)
@@ -77,55 +359,159 @@ func SendHeartbeat(v *viper.Viper) error {
 	if params.Network.SSLCertFilepath != "" {
 		withSSLCert, err := api.WithSSLCert(params.Network.SSLCertFilepath)
 		if err != nil {
-			return fmt.Errorf("failed to set up ssl cert option on api client: %w", err)
+			return nil, fmt.Errorf("failed to set up ssl cert option on api client: %w", err)
 		}
 
 		clientOpts = append(clientOpts, withSSLCert)
 	}
 
-	var userAgent string
 	if params.Plugin != "" {
-		userAgent = heartbeat.UserAgent(params.Plugin)
 		clientOpts = append(clientOpts, api.WithUserAgent(params.Plugin))
 	} else {
-		userAgent = heartbeat.UserAgentUnknownPlugin()
 		clientOpts = append(clientOpts, api.WithUserAgentUnknownPlugin())
 	}
 
-	c := api.NewClient(params.APIUrl, http.DefaultClient, clientOpts...)
+	return api.NewClient(apiURL, http.DefaultClient, clientOpts...), nil
+}
+
+// destinationName returns a name for the destination of apiURL and apiKey,
+// which only contains the last 4 characters of the api key.
+func destinationName(apiURL, apiKey string) string {
+	if len(apiKey) > 4 {
+		apiKey = apiKey[len(apiKey)-4:]
+	}
+
+	return fmt.Sprintf("%s (api key ...%s)", apiURL, apiKey)
+}
+
+// offlineQueueFilepath returns the offline queue db file of a destination.
+// The main destination uses the default file, [api_routes] destinations use
+// a file per api url and api key.
+func offlineQueueFilepath(params Params, apiURL, apiKey string) (string, error) {
+	fp, err := config.OfflineFilePath()
+	if err != nil {
+		return "", err
+	}
+
+	if apiURL == params.APIUrl && apiKey == params.APIKey {
+		return fp, nil
+	}
 
-	h := heartbeat.Heartbeat{
+	sum := sha256.Sum256([]byte(apiURL + "\x00" + apiKey))
+	ext := filepath.Ext(fp)
+
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(fp, ext), hex.EncodeToString(sum[:4]), ext), nil
+}
+
+// newHeartbeat creates the heartbeat to process from command parameters.
+func newHeartbeat(params Params) heartbeat.Heartbeat {
+	userAgent := heartbeat.UserAgentUnknownPlugin()
+	if params.Plugin != "" {
+		userAgent = heartbeat.UserAgent(params.Plugin)
+	}
+
+	return heartbeat.Heartbeat{
 		Entity:         params.Entity,
 		EntityType:     params.EntityType,
 		Category:       params.Category,
 		CursorPosition: params.CursorPosition,
 		IsWrite:        params.IsWrite,
+		Language:       params.Language,
 		LineNumber:     params.LineNumber,
 		Time:           params.Time,
 		UserAgent:      userAgent,
 	}
+}
+
+// handleOptions returns the heartbeat processing pipeline configured by
+// command parameters. Stages record their decisions to trace, if not nil.
+// With dryRun, stages do not write files.
+func handleOptions(params Params, trace *heartbeat.Trace, dryRun bool) []heartbeat.HandleOption {
+	projectConfig := project.Config{
+		Alternative:       params.Project.Alternate,
+		BranchMapPatterns: params.Project.BranchMapPatterns,
+		DryRun:            dryRun,
+		IssueKeyPattern:   params.Project.IssueKeyPattern,
+		MapPatterns:       params.Project.MapPatterns,
+		Normalize:         params.Project.Normalize,
+		ObfuscateKey:      params.Project.ObfuscateKey,
+		ObfuscatePatterns: params.Sanitize.HideProjectNames,
+		Override:          params.Project.Override,
+		SubmodulePatterns: params.Project.SubmodulePatterns,
+		Trace:             trace,
+	}
+
+	if params.Project.CacheFilepath != "" {
+		projectConfig.Cache = project.NewCache(params.Project.CacheFilepath, params.Project.ConfigFilepath)
+	}
+
+	var handleOpts []heartbeat.HandleOption
+
+	// pause needs to run first, so that no heartbeat is processed or queued while paused.
+	internalFilepath, err := config.InternalFilePath()
+	if err != nil {
+		jww.WARN.Printf("failed to get internal state file path: %s", err)
+	} else {
+		handleOpts = append(handleOpts, pause.WithPause(pause.Config{
+			FilePath: internalFilepath,
+			Trace:    trace,
+		}))
+	}
 
-	handleOpts := []heartbeat.HandleOption{
+	// filtering needs to run after detection, as it matches detected project,
+	// branch, language and category.
+	handleOpts = append(handleOpts,
+		project.WithDetection(projectConfig),
 		filter.WithFiltering(filter.Config{
 			Exclude:                    params.Filter.Exclude,
+			ExcludeBinaryFiles:         params.Filter.ExcludeBinaryFiles,
+			ExcludeBranches:            params.Filter.ExcludeBranches,
+			ExcludeCategories:          params.Filter.ExcludeCategories,
+			ExcludeGitIgnored:          params.Filter.ExcludeGitIgnored,
+			ExcludeLanguages:           params.Filter.ExcludeLanguages,
+			ExcludeProjects:            params.Filter.ExcludeProjects,
 			ExcludeUnknownProject:      params.Filter.ExcludeUnknownProject,
 			Include:                    params.Filter.Include,
+			IncludeBranches:            params.Filter.IncludeBranches,
+			IncludeCategories:          params.Filter.IncludeCategories,
+			IncludeLanguages:           params.Filter.IncludeLanguages,
 			IncludeOnlyWithProjectFile: params.Filter.IncludeOnlyWithProjectFile,
+			IncludeProjects:            params.Filter.IncludeProjects,
+			Trace:                      trace,
 		}),
-		filestats.WithDetection(),
-		heartbeat.WithSanitization(heartbeat.SanitizeConfig{
-			BranchPatterns:  params.Sanitize.HideBranchNames,
-			FilePatterns:    params.Sanitize.HideFileNames,
-			ProjectPatterns: params.Sanitize.HideProjectNames,
-		}),
-	}
+	)
 
-	handle := heartbeat.NewHandle(c, handleOpts...)
+	if params.Filter.Schedule != nil {
+		schedule := *params.Filter.Schedule
+		schedule.Trace = trace
 
-	_, err = handle([]heartbeat.Heartbeat{h})
-	if err != nil {
-		return fmt.Errorf("failed to send heartbeats via api client: %w", err)
+		handleOpts = append(handleOpts, filter.WithSchedule(schedule))
 	}
 
-	return nil
+	handleOpts = append(handleOpts,
+		filestats.WithDetection(filestats.Config{
+			MaxFileSize: params.FileStats.MaxFileSize,
+		}),
+		heartbeat.WithSanitization(heartbeat.SanitizeConfig{
+			BranchPatterns:    params.Sanitize.HideBranchNames,
+			DomainPatterns:    params.Sanitize.HideDomainNames,
+			FileNameHashKey:   params.Sanitize.FileNameHashKey,
+			FilePatterns:      params.Sanitize.HideFileNames,
+			HideProjectFolder: params.Sanitize.HideProjectFolder,
+			ProjectPatterns:   params.Sanitize.HideProjectNames,
+			RuleHashKey:       params.Sanitize.RuleHashKey,
+			Rules:             params.Sanitize.Rules,
+			SecretURLParams:   params.Sanitize.SecretURLParams,
+			Trace:             trace,
+			URLMode:           params.Sanitize.URLMode,
+		}),
+		// secret scanning needs to run last, so that it sees all values sent to the api.
+		secret.WithScanning(secret.Config{
+			Action:  params.Sanitize.SecretAction,
+			Entropy: params.Sanitize.SecretEntropy,
+			Trace:   trace,
+		}),
+	)
+
+	return handleOpts
 }
@@ -1,12 +1,15 @@
 package heartbeat_test
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -23,6 +26,9 @@ func TestSendHeartbeat(t *testing.T) {
 	testServerURL, router, tearDown := setupTestServer()
 	defer tearDown()
 
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
 	var (
 		plugin   = "plugin/0.0.1"
 		numCalls int
@@ -80,10 +86,251 @@ func TestSendHeartbeat(t *testing.T) {
 	assert.Eventually(t, func() bool { return numCalls == 1 }, time.Second, 50*time.Millisecond)
 }
 
+func TestSendHeartbeat_APIRoutes(t *testing.T) {
+	defaultServerURL, defaultRouter, tearDownDefault := setupTestServer()
+	defer tearDownDefault()
+
+	routedServerURL, routedRouter, tearDownRouted := setupTestServer()
+	defer tearDownRouted()
+
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	var numCallsDefault, numCallsRouted int
+
+	defaultRouter.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		numCallsDefault++
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	routedRouter.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		// api key of the [api_routes] entry
+		assert.Equal(t, []string{"Basic MDAwMDAwMDAtMDAwMC00MDAwLTgwMDAtMDAwMDAwMDAwMDAx"}, req.Header["Authorization"])
+
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), `"project":"wakatime-cli"`)
+
+		w.WriteHeader(http.StatusCreated)
+
+		f, err := os.Open("testdata/api_heartbeats_response.json")
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+		require.NoError(t, err)
+
+		numCallsRouted++
+	})
+
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err := v.ReadConfig(strings.NewReader(fmt.Sprintf(
+		"[api_routes]\n^wakatime-cli$ = 00000000-0000-4000-8000-000000000001 | api_url=%s\n",
+		routedServerURL,
+	)))
+	require.NoError(t, err)
+
+	v.Set("api-url", defaultServerURL)
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("plugin", "plugin/0.0.1")
+	v.Set("time", 1585598059.1)
+	v.Set("timeout", 5)
+
+	err = cmd.SendHeartbeat(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, numCallsDefault)
+	assert.Equal(t, 1, numCallsRouted)
+}
+
+func TestDryRun_APIRoutes(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err := v.ReadConfig(strings.NewReader(
+		"[api_routes]\n^wakatime-cli$ = 00000000-0000-4000-8000-000000000001 | api_url=https://wakapi.example.com/api\n",
+	))
+	require.NoError(t, err)
+
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("time", 1585598059.1)
+
+	output, err := cmd.DryRun(v)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, `[route] testdata/main.go: sending to https://wakapi.example.com/api (api key ...0001)`+
+		` by [api_routes] rule "^wakatime-cli$ = 00000000-0000-4000-8000-000000000001`+
+		` | api_url=https://wakapi.example.com/api"`)
+}
+
+func TestSendHeartbeat_APIMirrors(t *testing.T) {
+	mainServerURL, mainRouter, tearDownMain := setupTestServer()
+	defer tearDownMain()
+
+	mirrorServerURL, mirrorRouter, tearDownMirror := setupTestServer()
+	defer tearDownMirror()
+
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	var numCallsMain, numCallsMirror int
+
+	mainRouter.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		var hh []heartbeat.Heartbeat
+
+		err := json.NewDecoder(req.Body).Decode(&hh)
+		require.NoError(t, err)
+
+		// the main api never receives a heartbeat twice
+		assert.Len(t, hh, 1)
+
+		w.WriteHeader(http.StatusCreated)
+
+		f, err := os.Open("testdata/api_heartbeats_response.json")
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+		require.NoError(t, err)
+
+		numCallsMain++
+	})
+
+	mirrorRouter.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		var hh []heartbeat.Heartbeat
+
+		err := json.NewDecoder(req.Body).Decode(&hh)
+		require.NoError(t, err)
+
+		numCallsMirror++
+
+		// the mirror receives the heartbeat queued by its failed first call
+		assert.Len(t, hh, numCallsMirror)
+
+		// mirror is down
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	v := viper.New()
+	v.Set("api-url", mainServerURL)
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("plugin", "plugin/0.0.1")
+	v.Set("settings.api_mirrors", "00000000-0000-4000-8000-000000000001 | api_url="+mirrorServerURL)
+	v.Set("time", 1585598059.1)
+	v.Set("timeout", 5)
+
+	err := cmd.SendHeartbeat(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, numCallsMain)
+	assert.Equal(t, 1, numCallsMirror)
+
+	// the failed heartbeat is only queued for the mirror
+	err = cmd.SendHeartbeat(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, numCallsMain)
+	assert.Equal(t, 2, numCallsMirror)
+}
+
+func TestSendHeartbeat_ScheduleOutsideAPIKey(t *testing.T) {
+	testServerURL, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	var numCallsMain, numCallsOutside int
+
+	router.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		// the main api key never receives heartbeats outside of the schedule
+		if req.Header.Get("Authorization") != "Basic MDAwMDAwMDAtMDAwMC00MDAwLTgwMDAtMDAwMDAwMDAwMDAx" {
+			numCallsMain++
+
+			w.WriteHeader(http.StatusCreated)
+
+			return
+		}
+
+		var hh []heartbeat.Heartbeat
+
+		err := json.NewDecoder(req.Body).Decode(&hh)
+		require.NoError(t, err)
+
+		numCallsOutside++
+
+		// the queued heartbeat is sent with the outside api key again
+		assert.Len(t, hh, numCallsOutside)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err := v.ReadConfig(strings.NewReader(
+		"[schedule]\ndays = sat-sun\ntimezone = UTC\noutside_api_key = 00000000-0000-4000-8000-000000000001\n",
+	))
+	require.NoError(t, err)
+
+	v.Set("api-url", testServerURL)
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("plugin", "plugin/0.0.1")
+	v.Set("time", 1585598059.1)
+	v.Set("timeout", 5)
+
+	err = cmd.SendHeartbeat(v)
+	require.Error(t, err)
+
+	err = cmd.SendHeartbeat(v)
+	require.Error(t, err)
+
+	assert.Equal(t, 0, numCallsMain)
+	assert.Equal(t, 2, numCallsOutside)
+}
+
+func TestDryRun_APIMirrors(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	v := viper.New()
+	v.Set("api-url", "https://api.wakatime.com/api/v1")
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("settings.api_mirrors", "00000000-0000-4000-8000-000000000001 | api_url=https://wakapi.example.com/api")
+	v.Set("time", 1585598059.1)
+
+	output, err := cmd.DryRun(v)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "[fanout] sent 1 heartbeat(s) to https://api.wakatime.com/api/v1 (api key ...0000)")
+	assert.Contains(t, output, "[fanout] sent 1 heartbeat(s) to https://wakapi.example.com/api (api key ...0001)")
+	assert.Equal(t, 1, strings.Count(output, `"entity": `))
+}
+
 func TestSendHeartbeat_WithFiltering_Exclude(t *testing.T) {
 	testServerURL, router, tearDown := setupTestServer()
 	defer tearDown()
 
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
 	var numCalls int
 
 	router.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
@@ -110,9 +357,169 @@ func TestSendHeartbeat_WithFiltering_Exclude(t *testing.T) {
 	assert.Equal(t, 0, numCalls)
 }
 
+func TestSendHeartbeat_OfflineEncryption(t *testing.T) {
+	testServerURL, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	router.HandleFunc("/v1/users/current/heartbeats.bulk", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	keyFilepath := filepath.Join(os.Getenv("WAKATIME_HOME"), "offline.key")
+
+	v := viper.New()
+	v.Set("api-url", testServerURL)
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("settings.offline_encryption", true)
+	v.Set("settings.offline_key_file", keyFilepath)
+	v.Set("time", 1585598059.1)
+	v.Set("timeout", 5)
+
+	err := cmd.SendHeartbeat(v)
+	require.Error(t, err)
+
+	assert.FileExists(t, keyFilepath)
+
+	db, err := sql.Open("sqlite3", filepath.Join(os.Getenv("WAKATIME_HOME"), ".wakatime.db"))
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	var data string
+
+	err = db.QueryRow("SELECT heartbeat FROM heartbeat_2").Scan(&data)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(data, "enc:v1:"))
+	assert.NotContains(t, data, "main.go")
+}
+
+func TestDryRun(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	v := viper.New()
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("hide-file-names", "main")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("time", 1585598059.1)
+
+	output, err := cmd.DryRun(v)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, `"entity": "HIDDEN.go"`)
+	assert.Contains(t, output, `[project] testdata/main.go: project "wakatime-cli" set by project-file-detector`)
+	assert.Contains(t, output, "trace:\n")
+	assert.Contains(t, output, `[filter] testdata/main.go: passed all filters`)
+	assert.Contains(t, output, `[sanitize] entity matches hide_file_names pattern "main". hiding file name and metadata`)
+}
+
+func TestDryRun_HideProjectNames_Pattern(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime-git")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	err = os.MkdirAll(filepath.Join(tmpDir, "acme", ".git"), 0700)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "acme", ".git", "HEAD"), []byte("ref: refs/heads/feature\n"), 0600)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "acme", ".git", "config"), []byte("[core]\n"), 0600)
+	require.NoError(t, err)
+
+	entity := filepath.Join(tmpDir, "acme", "main.go")
+
+	err = ioutil.WriteFile(entity, []byte("package main\n"), 0600)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("entity", entity)
+	v.Set("entity-type", "file")
+	v.Set("hide-project-names", "acme")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("lineno", 13)
+	v.Set("time", 1585598059.1)
+
+	for i := 0; i < 2; i++ {
+		output, err := cmd.DryRun(v)
+		require.NoError(t, err)
+
+		assert.Contains(t, output, "set by obfuscation")
+		assert.NotContains(t, output, `"project": "acme"`)
+		assert.Contains(t, output, `"lineno": null`)
+		assert.Contains(t, output, `"branch": null`)
+	}
+}
+
+func TestDryRun_NoProjectCache(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	v := viper.New()
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("time", 1585598059.1)
+
+	_, err := cmd.DryRun(v)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, filepath.Join(os.Getenv("WAKATIME_HOME"), ".wakatime-project-cache.json"))
+}
+
+func TestDryRun_Filtered(t *testing.T) {
+	tearDownHome := setupTestWakaHome(t)
+	defer tearDownHome()
+
+	v := viper.New()
+	v.Set("entity", "testdata/main.go")
+	v.Set("entity-type", "file")
+	v.Set("exclude", []string{"^testdata/"})
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("time", 1585598059.1)
+
+	output, err := cmd.DryRun(v)
+	require.NoError(t, err)
+
+	lines := strings.Split(output, "\n")
+
+	assert.Equal(t, "no heartbeat left to send", lines[0])
+	assert.Contains(
+		t,
+		lines,
+		`[filter] testdata/main.go: skipping because matches exclude pattern "^testdata/"`,
+	)
+}
+
 func setupTestServer() (string, *http.ServeMux, func()) {
 	router := http.NewServeMux()
 	srv := httptest.NewServer(router)
 
 	return srv.URL, router, func() { srv.Close() }
 }
+
+// setupTestWakaHome points WAKATIME_HOME to a temporary directory, so that
+// files like the project cache are not written to the user's home directory.
+func setupTestWakaHome(t *testing.T) func() {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime-home")
+	require.NoError(t, err)
+
+	err = os.Setenv("WAKATIME_HOME", tmpDir)
+	require.NoError(t, err)
+
+	return func() {
+		os.Unsetenv("WAKATIME_HOME")
+		os.RemoveAll(tmpDir)
+	}
+}
@@ -4,52 +4,99 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/config"
+	"github.com/wakatime/wakatime-cli/pkg/filestats"
+	"github.com/wakatime/wakatime-cli/pkg/filter"
+	"github.com/wakatime/wakatime-cli/pkg/glob"
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+	"github.com/wakatime/wakatime-cli/pkg/secret"
 	"github.com/wakatime/wakatime-cli/pkg/vipertools"
 
+	"github.com/mitchellh/go-homedir"
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/spf13/viper"
 )
 
+// projectCacheFile is the name of the project detection cache file in wakatime home dir.
+const projectCacheFile = ".wakatime-project-cache.json"
+
 var (
 	// nolint
-	apiKeyRegex = regexp.MustCompile("^[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89ab][a-f0-9]{3}-[a-f0-9]{12}$")
+	envVarRegex = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}|\$[A-Za-z_][A-Za-z0-9_]*`)
 	// nolint
-	matchAllRegex = regexp.MustCompile(".*")
+	envVarBracedRegex = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
 	// nolint
-	proxyRegex = regexp.MustCompile(`^((https?|socks5)://)?([^:@]+(:([^:@])+)?@)?[^:]+(:\d+)?$`)
+	matchAllRegex = regexp.MustCompile(".*")
 )
 
 // Params contains heartbeat command parameters.
 type Params struct {
 	APIKey         string
+	APIMirrors     []APIMirror
+	APIRoutes      []APIRoute
 	APIUrl         string
 	Category       heartbeat.Category
 	CursorPosition *int
 	Entity         string
 	EntityType     heartbeat.EntityType
+	FileStats      FileStatsParams
 	Hostname       string
 	IsWrite        *bool
+	Language       *string
 	LineNumber     *int
 	Plugin         string
 	Time           float64
 	Timeout        time.Duration
 	Filter         FilterParams
 	Network        NetworkParams
+	Offline        OfflineParams
+	Project        ProjectParams
 	Sanitize       SanitizeParams
 }
 
+// APIRoute contains an [api_routes] entry, sending heartbeats with a
+// project or entity matching Pattern to a different api key and url.
+type APIRoute struct {
+	APIKey  string
+	APIUrl  string
+	Pattern *regexp.Regexp
+	// Rule is the original config entry, used for debugging output.
+	Rule string
+}
+
+// APIMirror contains a settings.api_mirrors entry. Every heartbeat sent to the
+// main api is sent to the mirror as well.
+type APIMirror struct {
+	APIKey string
+	APIUrl string
+}
+
 // FilterParams contains heartbeat filtering related command parameters.
 type FilterParams struct {
-	Exclude                    []*regexp.Regexp
+	Exclude                    []matcher.Matcher
+	ExcludeBinaryFiles         bool
+	ExcludeBranches            []matcher.Matcher
+	ExcludeCategories          []matcher.Matcher
+	ExcludeGitIgnored          bool
+	ExcludeLanguages           []matcher.Matcher
+	ExcludeProjects            []matcher.Matcher
 	ExcludeUnknownProject      bool
-	Include                    []*regexp.Regexp
+	Include                    []matcher.Matcher
+	IncludeBranches            []matcher.Matcher
+	IncludeCategories          []matcher.Matcher
+	IncludeLanguages           []matcher.Matcher
 	IncludeOnlyWithProjectFile bool
+	IncludeProjects            []matcher.Matcher
+	Schedule                   *filter.ScheduleConfig
 }
 
 // NetworkParams contains network related command parameters.
@@ -59,23 +106,75 @@ type NetworkParams struct {
 	SSLCertFilepath  string
 }
 
+// OfflineParams contains offline queue related command parameters.
+type OfflineParams struct {
+	// Encryption is set, if queued heartbeats are encrypted.
+	Encryption bool
+	// KeyFilepath is an optional file containing the encryption key. If not
+	// set, the key is derived from the api key of each queue.
+	KeyFilepath string
+}
+
+// ProjectParams params for project detection.
+type ProjectParams struct {
+	Alternate         string
+	BranchMapPatterns []project.MapPattern
+	CacheFilepath     string
+	ConfigFilepath    string
+	IssueKeyPattern   *regexp.Regexp
+	MapPatterns       []project.MapPattern
+	Normalize         project.NormalizeConfig
+	// ObfuscateKey is settings.hide_file_names_key, used to derive obfuscated
+	// project names, if they cannot be persisted.
+	ObfuscateKey      []byte
+	Override          string
+	SubmodulePatterns []matcher.Matcher
+}
+
+// FileStatsParams contains file stats detection related command parameters.
+type FileStatsParams struct {
+	// MaxFileSize is the max file size in bytes supporting line number count stats.
+	MaxFileSize int64
+}
+
 // SanitizeParams params for heartbeat sanitization.
 type SanitizeParams struct {
-	HideBranchNames  []*regexp.Regexp
-	HideFileNames    []*regexp.Regexp
-	HideProjectNames []*regexp.Regexp
+	HideBranchNames  []matcher.Matcher
+	HideDomainNames  []matcher.Matcher
+	HideFileNames    []matcher.Matcher
+	HideProjectNames []matcher.Matcher
+	// HideProjectFolder is set, if file entities are sent relative to the project folder.
+	HideProjectFolder bool
+	// FileNameHashKey is set, if hidden file names are hashed instead of replaced by "HIDDEN".
+	FileNameHashKey []byte
+	// Rules contains the [sanitize] rules in config file order.
+	Rules []heartbeat.SanitizeRule
+	// RuleHashKey is settings.hide_file_names_key, used by rules to hash fields.
+	RuleHashKey []byte
+	// URLMode defines how much of domain entities is sent.
+	URLMode heartbeat.URLMode
+	// SecretURLParams are removed from domain entities in addition to the default ones.
+	SecretURLParams []string
+	// SecretAction defines what happens to heartbeats containing secrets.
+	SecretAction secret.Action
+	// SecretEntropy enables the detection of high entropy tokens as secrets.
+	SecretEntropy bool
 }
 
 // LoadParams loads heartbeat config params from viper.Viper instance. Returns ErrAuth
 // if failed to retrieve api key.
 func LoadParams(v *viper.Viper) (Params, error) {
-	apiKey, ok := vipertools.FirstNonEmptyString(v, "key", "settings.api_key", "settings.apikey")
-	if !ok {
-		return Params{}, api.ErrAuth("failed to load api key")
+	if err := validateStrict(v); err != nil {
+		return Params{}, err
 	}
 
-	if !apiKeyRegex.Match([]byte(apiKey)) {
-		return Params{}, api.ErrAuth("invalid api key format")
+	apiKey, err := config.APIKey(v)
+	if err != nil {
+		return Params{}, api.ErrAuth(fmt.Sprintf("failed to load api key: %s", err))
+	}
+
+	if apiKey == "" {
+		return Params{}, api.ErrAuth("failed to load api key")
 	}
 
 	apiURL := api.BaseURL
@@ -83,6 +182,25 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		apiURL = url
 	}
 
+	keyFormats, err := config.APIKeyFormats(v)
+	if err != nil {
+		return Params{}, err
+	}
+
+	if err := api.ValidateKey(apiKey, api.KeyFormats(apiURL, keyFormats...)); err != nil {
+		return Params{}, err
+	}
+
+	apiRoutes, err := loadAPIRoutes(v, apiKey, apiURL, keyFormats)
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to load api routes: %w", err)
+	}
+
+	apiMirrors, err := loadAPIMirrors(v, apiKey, apiURL, keyFormats)
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to load api mirrors: %w", err)
+	}
+
 	var category heartbeat.Category
 
 	if categoryStr := v.GetString("category"); categoryStr != "" {
@@ -115,8 +233,6 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		entityType = parsed
 	}
 
-	var err error
-
 	hostname, ok := vipertools.FirstNonEmptyString(v, "hostname", "settings.hostname")
 	if !ok {
 		hostname, err = os.Hostname()
@@ -130,6 +246,11 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		isWrite = heartbeat.Bool(b)
 	}
 
+	var language *string
+	if l := strings.TrimSpace(v.GetString("language")); l != "" {
+		language = heartbeat.String(l)
+	}
+
 	var lineNumber *int
 	if num := v.GetInt("lineno"); v.IsSet("lineno") {
 		lineNumber = heartbeat.Int(num)
@@ -140,6 +261,11 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		timeSecs = float64(time.Now().UnixNano()) / 1000000000
 	}
 
+	fileStatsParams, err := loadFileStatsParams(v)
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to load file stats params: %s", err)
+	}
+
 	var timeout time.Duration
 
 	timeoutSecs, ok := vipertools.FirstNonEmptyInt(v, "timeout", "settings.timeout")
@@ -152,6 +278,21 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		return Params{}, fmt.Errorf("failed to parse network params: %s", err)
 	}
 
+	offlineParams := OfflineParams{
+		Encryption:  v.GetBool("settings.offline_encryption"),
+		KeyFilepath: strings.TrimSpace(v.GetString("settings.offline_key_file")),
+	}
+
+	projectParams, err := loadProjectParams(v)
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to load project params: %s", err)
+	}
+
+	filterParams, err := loadFilterParams(v, api.KeyFormats(apiURL, keyFormats...))
+	if err != nil {
+		return Params{}, fmt.Errorf("failed to load filter params: %s", err)
+	}
+
 	sanitizeParams, err := loadSanitizeParams(v)
 	if err != nil {
 		return Params{}, fmt.Errorf("failed to load sanitize params: %s", err)
@@ -159,69 +300,337 @@ func LoadParams(v *viper.Viper) (Params, error) {
 
 	return Params{
 		APIKey:         apiKey,
+		APIMirrors:     apiMirrors,
+		APIRoutes:      apiRoutes,
 		APIUrl:         apiURL,
 		Category:       category,
 		CursorPosition: cursorPosition,
 		Entity:         entity,
 		EntityType:     entityType,
+		FileStats:      fileStatsParams,
 		Hostname:       hostname,
 		IsWrite:        isWrite,
+		Language:       language,
 		LineNumber:     lineNumber,
 		Plugin:         v.GetString("plugin"),
 		Time:           timeSecs,
 		Timeout:        timeout,
-		Filter:         loadFilterParams(v),
+		Filter:         filterParams,
 		Network:        networkParams,
+		Offline:        offlineParams,
+		Project:        projectParams,
 		Sanitize:       sanitizeParams,
 	}, nil
 }
 
-func loadFilterParams(v *viper.Viper) FilterParams {
-	exclude := v.GetStringSlice("exclude")
-	exclude = append(exclude, v.GetStringSlice("settings.exclude")...)
-	exclude = append(exclude, v.GetStringSlice("settings.ignore")...)
+// loadAPIRoutes loads the [api_routes] section. Keys are patterns like in
+// [projectmap], matched against the project and the entity. Values contain an
+// api key and optionally an api url, separated by "|". An empty api key or
+// missing api url default to the main ones:
+//
+//	"glob:~/clients/**" = 00000000-0000-4000-8000-000000000000 | api_url=https://wakapi.example.com/api
+//
+// Routes are sorted like [projectmap] patterns, longer patterns first.
+func loadAPIRoutes(v *viper.Viper, apiKey, apiURL string, keyFormats []api.KeyFormat) ([]APIRoute, error) {
+	var routes []APIRoute
+
+	for key, value := range vipertools.GetStringMapString(v, "api_routes") {
+		// skipping a route would send its heartbeats to the main api key
+		if key == unquotedGlobKey {
+			return nil, fmt.Errorf("invalid api_routes pattern %q: %s", key+":"+value, errUnquotedGlob)
+		}
 
-	var excludePatterns []*regexp.Regexp
+		rule := fmt.Sprintf("%s = %s", key, value)
 
-	for _, s := range exclude {
-		compiled, err := regexp.Compile(s)
+		compiled, err := compileProjectMapKey(key)
 		if err != nil {
-			jww.DEBUG.Printf("failed to compile exclude regex pattern %q", s)
-			continue
+			return nil, fmt.Errorf("failed to compile api_routes pattern %q: %s", key, err)
 		}
 
-		excludePatterns = append(excludePatterns, compiled)
+		route, err := parseAPIRouteValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse api_routes value of pattern %q: %w", key, err)
+		}
+
+		if route.APIKey == "" {
+			route.APIKey = apiKey
+		}
+
+		if route.APIUrl == "" {
+			route.APIUrl = apiURL
+		}
+
+		if err := api.ValidateKey(route.APIKey, api.KeyFormats(route.APIUrl, keyFormats...)); err != nil {
+			return nil, fmt.Errorf("failed to parse api_routes value of pattern %q: %w", key, err)
+		}
+
+		route.Pattern = compiled
+		route.Rule = rule
+
+		routes = append(routes, route)
 	}
 
-	include := v.GetStringSlice("include")
-	include = append(include, v.GetStringSlice("settings.include")...)
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i].Pattern.String(), routes[j].Pattern.String()
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
 
-	var includePatterns []*regexp.Regexp
+		return a < b
+	})
 
-	for _, s := range include {
-		compiled, err := regexp.Compile(s)
-		if err != nil {
-			jww.DEBUG.Printf("failed to compile include regex pattern %q", s)
+	return routes, nil
+}
+
+// loadAPIMirrors loads settings.api_mirrors, one mirror per line. Lines have
+// the format of [api_routes] values, but require an api url. An empty api key
+// defaults to the main one:
+//
+//	api_mirrors = """00000000-0000-4000-8000-000000000000 | api_url=https://wakapi.example.com/api"""
+func loadAPIMirrors(v *viper.Viper, apiKey, apiURL string, keyFormats []api.KeyFormat) ([]APIMirror, error) {
+	var mirrors []APIMirror
+
+	for _, line := range strings.Split(v.GetString("settings.api_mirrors"), "\n") {
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		includePatterns = append(includePatterns, compiled)
+		parsed, err := parseAPIRouteValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse api_mirrors entry: %w", err)
+		}
+
+		if parsed.APIUrl == "" {
+			return nil, fmt.Errorf("missing api_url option in api_mirrors entry")
+		}
+
+		mirror := APIMirror{
+			APIKey: parsed.APIKey,
+			APIUrl: parsed.APIUrl,
+		}
+
+		if mirror.APIKey == "" {
+			mirror.APIKey = apiKey
+		}
+
+		if err := api.ValidateKey(mirror.APIKey, api.KeyFormats(mirror.APIUrl, keyFormats...)); err != nil {
+			return nil, fmt.Errorf("failed to parse api_mirrors entry: %w", err)
+		}
+
+		if mirror.APIUrl == apiURL && mirror.APIKey == apiKey {
+			return nil, fmt.Errorf("api_mirrors entry with api url %q is the main api", mirror.APIUrl)
+		}
+
+		mirrors = append(mirrors, mirror)
+	}
+
+	return mirrors, nil
+}
+
+// parseAPIRouteValue parses an [api_routes] value of the form "api_key | api_url=url".
+func parseAPIRouteValue(value string) (APIRoute, error) {
+	parts := strings.Split(value, "|")
+
+	route := APIRoute{
+		APIKey: strings.TrimSpace(parts[0]),
+	}
+
+	for _, part := range parts[1:] {
+		option := strings.SplitN(part, "=", 2)
+		if len(option) != 2 {
+			return APIRoute{}, fmt.Errorf("invalid option %q", strings.TrimSpace(part))
+		}
+
+		key, val := strings.TrimSpace(option[0]), strings.TrimSpace(option[1])
+
+		switch key {
+		case "api_url":
+			route.APIUrl = val
+		default:
+			return APIRoute{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	return route, nil
+}
+
+// validateStrict validates the config file, if strict mode is enabled via
+// settings.strict. Returns config.ErrFileParse, if problems were found.
+func validateStrict(v *viper.Viper) error {
+	if !v.GetBool("settings.strict") {
+		return nil
+	}
+
+	fp, err := config.FilePath(v)
+	if err != nil {
+		return fmt.Errorf("failed to get config file path: %s", err)
+	}
+
+	problems, err := config.Validate(fp)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(problems))
+	for i, p := range problems {
+		messages[i] = p.String()
+	}
+
+	return config.ErrFileParse(fmt.Sprintf(
+		"strict mode: %d problem(s) found in config file. %s",
+		len(problems),
+		strings.Join(messages, ". "),
+	))
+}
+
+func loadFileStatsParams(v *viper.Viper) (FileStatsParams, error) {
+	maxFileSize := filestats.DefaultMaxFileSize
+
+	if v.IsSet("settings.file_stats_max_size") {
+		maxFileSize = v.GetInt("settings.file_stats_max_size")
+		if maxFileSize <= 0 {
+			return FileStatsParams{}, fmt.Errorf(
+				"invalid file_stats_max_size %q. must be a positive number of bytes",
+				v.GetString("settings.file_stats_max_size"),
+			)
+		}
+	}
+
+	return FileStatsParams{
+		MaxFileSize: int64(maxFileSize),
+	}, nil
+}
+
+func loadFilterParams(v *viper.Viper, keyFormats []api.KeyFormat) (FilterParams, error) {
+	exclude := v.GetStringSlice("exclude")
+	exclude = append(exclude, v.GetStringSlice("settings.exclude")...)
+	exclude = append(exclude, v.GetStringSlice("settings.ignore")...)
+
+	include := v.GetStringSlice("include")
+	include = append(include, v.GetStringSlice("settings.include")...)
+
+	schedule, err := loadScheduleConfig(v, keyFormats)
+	if err != nil {
+		return FilterParams{}, fmt.Errorf("failed to load schedule: %s", err)
 	}
 
 	return FilterParams{
-		Exclude: excludePatterns,
+		Exclude: compileMatcherList("exclude", exclude),
+		ExcludeBinaryFiles: vipertools.FirstNonEmptyBool(
+			v,
+			"exclude-binary-files",
+			"settings.exclude_binary_files",
+		),
+		ExcludeBranches:   compileMatcherList("exclude branches", v.GetStringSlice("settings.exclude_branches")),
+		ExcludeCategories: compileMatcherList("exclude categories", v.GetStringSlice("settings.exclude_categories")),
+		ExcludeGitIgnored: vipertools.FirstNonEmptyBool(
+			v,
+			"exclude-git-ignored",
+			"settings.exclude_git_ignored",
+		),
+		ExcludeLanguages: compileMatcherList("exclude languages", v.GetStringSlice("settings.exclude_languages")),
+		ExcludeProjects:  compileMatcherList("exclude projects", v.GetStringSlice("settings.exclude_projects")),
 		ExcludeUnknownProject: vipertools.FirstNonEmptyBool(
 			v,
 			"exclude-unknown-project",
 			"settings.exclude_unknown_project",
 		),
-		Include: includePatterns,
+		Include:           compileMatcherList("include", include),
+		IncludeBranches:   compileMatcherList("include branches", v.GetStringSlice("settings.include_branches")),
+		IncludeCategories: compileMatcherList("include categories", v.GetStringSlice("settings.include_categories")),
+		IncludeLanguages:  compileMatcherList("include languages", v.GetStringSlice("settings.include_languages")),
 		IncludeOnlyWithProjectFile: vipertools.FirstNonEmptyBool(
 			v,
 			"include-only-with-project-file",
 			"settings.include_only_with_project_file",
 		),
+		IncludeProjects: compileMatcherList("include projects", v.GetStringSlice("settings.include_projects")),
+		Schedule:        schedule,
+	}, nil
+}
+
+// loadScheduleConfig loads the [schedule] section. Returns nil, if no schedule is configured.
+// The outside_api_key must match one of keyFormats.
+func loadScheduleConfig(v *viper.Viper, keyFormats []api.KeyFormat) (*filter.ScheduleConfig, error) {
+	if len(vipertools.GetStringMapString(v, "schedule")) == 0 {
+		return nil, nil
+	}
+
+	days, err := filter.ParseWeekdays(v.GetString("schedule.days"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse days: %s", err)
+	}
+
+	windows, err := filter.ParseTimeWindows(v.GetString("schedule.hours"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hours: %s", err)
+	}
+
+	location := time.Local
+
+	if tz := strings.TrimSpace(v.GetString("schedule.timezone")); tz != "" {
+		location, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load timezone %q: %s", tz, err)
+		}
+	}
+
+	var holidays map[string]struct{}
+
+	if fp := strings.TrimSpace(v.GetString("schedule.holidays_file")); fp != "" {
+		expanded, err := homedir.Expand(fp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand holidays file path %q: %s", fp, err)
+		}
+
+		holidays, err = filter.LoadHolidays(expanded)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outsideAPIKey := strings.TrimSpace(v.GetString("schedule.outside_api_key"))
+	if outsideAPIKey != "" && api.ValidateKey(outsideAPIKey, keyFormats) != nil {
+		return nil, errors.New("invalid outside_api_key format")
 	}
+
+	return &filter.ScheduleConfig{
+		Days:           days,
+		Windows:        windows,
+		Location:       location,
+		Holidays:       holidays,
+		OutsideAPIKey:  outsideAPIKey,
+		OutsideProject: strings.TrimSpace(v.GetString("schedule.outside_project")),
+	}, nil
+}
+
+// compileMatcherList compiles regex and glob patterns. Invalid patterns are
+// logged and skipped.
+func compileMatcherList(name string, list []string) []matcher.Matcher {
+	var patterns []matcher.Matcher
+
+	for _, s := range list {
+		compiled, err := matcher.Compile(s)
+		if err != nil {
+			jww.WARN.Printf(
+				"failed to compile %s pattern %q: %s. use the %q prefix for glob patterns like *.log",
+				name,
+				s,
+				err,
+				matcher.GlobPrefix,
+			)
+
+			continue
+		}
+
+		patterns = append(patterns, compiled)
+	}
+
+	return patterns
 }
 
 func loadNetworkParams(v *viper.Viper) (NetworkParams, error) {
@@ -235,7 +644,7 @@ func loadNetworkParams(v *viper.Viper) (NetworkParams, error) {
 		"'domain\\user:pass.'"
 
 	proxyURL, _ := vipertools.FirstNonEmptyString(v, "proxy", "settings.proxy")
-	if proxyURL != "" && !proxyRegex.MatchString(proxyURL) {
+	if proxyURL != "" && api.ValidateProxyURL(proxyURL) != nil {
 		return NetworkParams{}, fmt.Errorf(errMsgTemplate, proxyURL)
 	}
 
@@ -248,6 +657,289 @@ func loadNetworkParams(v *viper.Viper) (NetworkParams, error) {
 	}, nil
 }
 
+func loadProjectParams(v *viper.Viper) (ProjectParams, error) {
+	submodulesDisabled, _ := vipertools.FirstNonEmptyString(v, "git.submodules_disabled")
+
+	submodulePatterns, err := parseBoolOrRegexList(submodulesDisabled)
+	if err != nil {
+		return ProjectParams{}, fmt.Errorf(
+			"failed to parse regex submodules disabled param %q: %s",
+			submodulesDisabled,
+			err,
+		)
+	}
+
+	var issueKeyPattern *regexp.Regexp
+
+	if issueKeyStr, ok := vipertools.FirstNonEmptyString(v, "settings.issue_key_pattern"); ok {
+		issueKeyPattern, err = regexp.Compile(issueKeyStr)
+		if err != nil {
+			return ProjectParams{}, fmt.Errorf("failed to compile issue key regex pattern %q: %s", issueKeyStr, err)
+		}
+	}
+
+	normalize, err := loadProjectNormalizeConfig(v)
+	if err != nil {
+		return ProjectParams{}, fmt.Errorf("failed to load project normalization config: %s", err)
+	}
+
+	var cacheFilepath string
+
+	home, err := config.WakaHomeDir()
+	if err != nil {
+		jww.WARN.Printf("failed to retrieve wakatime home dir. project cache disabled: %s", err)
+	} else {
+		cacheFilepath = filepath.Join(home, projectCacheFile)
+	}
+
+	configFilepath, err := config.FilePath(v)
+	if err != nil {
+		jww.WARN.Printf("failed to retrieve config file path: %s", err)
+	}
+
+	var obfuscateKey []byte
+	if key := strings.TrimSpace(v.GetString("settings.hide_file_names_key")); key != "" {
+		obfuscateKey = []byte(key)
+	}
+
+	return ProjectParams{
+		Alternate:         v.GetString("alternate-project"),
+		BranchMapPatterns: loadMapPatterns(v, "branchmap"),
+		CacheFilepath:     cacheFilepath,
+		ConfigFilepath:    configFilepath,
+		IssueKeyPattern:   issueKeyPattern,
+		MapPatterns:       LoadProjectMapPatterns(v),
+		Normalize:         normalize,
+		ObfuscateKey:      obfuscateKey,
+		Override:          v.GetString("project"),
+		SubmodulePatterns: submodulePatterns,
+	}, nil
+}
+
+// loadProjectNormalizeConfig loads the project name normalization rules from
+// the [project_normalization], [project_replace] and [project_aliases] sections.
+func loadProjectNormalizeConfig(v *viper.Viper) (project.NormalizeConfig, error) {
+	var suffixes []*regexp.Regexp
+
+	for _, s := range strings.Split(v.GetString("project_normalization.strip_suffixes"), "\n") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		compiled, err := regexp.Compile("(?:" + s + ")$")
+		if err != nil {
+			return project.NormalizeConfig{}, fmt.Errorf("failed to compile strip suffix regex %q: %s", s, err)
+		}
+
+		suffixes = append(suffixes, compiled)
+	}
+
+	var replace []project.ReplacePattern
+
+	for _, pattern := range loadMapPatterns(v, "project_replace") {
+		replace = append(replace, project.ReplacePattern{
+			Regex:       pattern.Regex,
+			Replacement: pattern.Name,
+		})
+	}
+
+	aliases, err := project.LowercaseAliases(vipertools.GetStringMapString(v, "project_aliases"))
+	if err != nil {
+		return project.NormalizeConfig{}, fmt.Errorf("failed to load project aliases: %s", err)
+	}
+
+	return project.NormalizeConfig{
+		Lowercase:     vipertools.FirstNonEmptyBool(v, "project_normalization.lowercase"),
+		StripSuffixes: suffixes,
+		Replace:       replace,
+		Aliases:       aliases,
+	}, nil
+}
+
+// loadMapPatterns loads regex patterns and their templates from a config
+// section like [projectmap] or [branchmap]. Invalid patterns will be skipped.
+func loadMapPatterns(v *viper.Viper, section string) []project.MapPattern {
+	var patterns []project.MapPattern
+
+	for key, name := range vipertools.GetStringMapString(v, section) {
+		// viper lowercases all config keys, so patterns are matched case insensitive.
+		compiled, err := regexp.Compile("(?i)" + key)
+		if err != nil {
+			jww.WARN.Printf("failed to compile %s regex pattern %q", section, key)
+			continue
+		}
+
+		patterns = append(patterns, project.MapPattern{
+			Name:  name,
+			Regex: compiled,
+		})
+	}
+
+	sortMapPatterns(patterns)
+
+	return patterns
+}
+
+// sortMapPatterns sorts patterns in place. viper does not preserve the order
+// of config keys, so longer and therefore usually more specific patterns are
+// matched first.
+func sortMapPatterns(patterns []project.MapPattern) {
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i].Regex.String(), patterns[j].Regex.String()
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+
+		return a < b
+	})
+}
+
+// unquotedGlobKey is the key the ini parser makes of unquoted keys with a
+// "glob:" prefix, as it splits keys at the first colon. Several such keys
+// collapse into one, so all but the last pattern would be lost.
+const unquotedGlobKey = "glob"
+
+// errUnquotedGlob is the reason for rejecting unquoted "glob:" keys.
+// nolint
+var errUnquotedGlob = errors.New(`glob patterns must be quoted, like "glob:~/work/*/" = project`)
+
+// LoadProjectMapPatterns loads the [projectmap] patterns. Keys are regular
+// expressions or, with a "glob:" prefix, glob patterns. A leading "~" expands
+// to the user's home directory, environment variables are expanded via ${VAR}
+// and in glob patterns also via $VAR. Values contain the project name and
+// optionally a branch and a category, separated by "|":
+//
+//	"glob:~/work/*/" = client-{0} | branch=main | category=code reviewing
+//
+// Invalid patterns and unquoted "glob:" keys will be skipped.
+func LoadProjectMapPatterns(v *viper.Viper) []project.MapPattern {
+	var patterns []project.MapPattern
+
+	for key, value := range vipertools.GetStringMapString(v, "projectmap") {
+		if key == unquotedGlobKey {
+			jww.WARN.Printf("skipping projectmap pattern %q: %s", key+":"+value, errUnquotedGlob)
+			continue
+		}
+
+		rule := fmt.Sprintf("%s = %s", key, value)
+
+		compiled, err := compileProjectMapKey(key)
+		if err != nil {
+			jww.WARN.Printf("failed to compile projectmap pattern %q: %s", key, err)
+			continue
+		}
+
+		pattern, err := parseProjectMapValue(value)
+		if err != nil {
+			jww.WARN.Printf("failed to parse projectmap value %q: %s", value, err)
+			continue
+		}
+
+		pattern.Regex = compiled
+		pattern.Rule = rule
+
+		patterns = append(patterns, pattern)
+	}
+
+	sortMapPatterns(patterns)
+
+	return patterns
+}
+
+// compileProjectMapKey compiles a [projectmap] key into a regular expression.
+// viper lowercases all config keys, so patterns are matched case insensitive.
+func compileProjectMapKey(key string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(key, "glob:") {
+		expanded, err := expandPath(strings.TrimPrefix(key, "glob:"), false, glob.QuoteMeta)
+		if err != nil {
+			return nil, err
+		}
+
+		expr, err := glob.ToRegex(expanded)
+		if err != nil {
+			return nil, err
+		}
+
+		return regexp.Compile("(?i)" + expr)
+	}
+
+	expanded, err := expandPath(key, true, regexp.QuoteMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	return regexp.Compile("(?i)" + expanded)
+}
+
+// parseProjectMapValue parses a [projectmap] value of the form
+// "name | branch=name | category=name".
+func parseProjectMapValue(value string) (project.MapPattern, error) {
+	parts := strings.Split(value, "|")
+
+	pattern := project.MapPattern{
+		Name: strings.TrimSpace(parts[0]),
+	}
+
+	for _, part := range parts[1:] {
+		option := strings.SplitN(part, "=", 2)
+		if len(option) != 2 {
+			return project.MapPattern{}, fmt.Errorf("invalid option %q", strings.TrimSpace(part))
+		}
+
+		key, val := strings.TrimSpace(option[0]), strings.TrimSpace(option[1])
+
+		switch key {
+		case "branch":
+			pattern.Branch = val
+		case "category":
+			category, err := heartbeat.ParseCategory(val)
+			if err != nil {
+				return project.MapPattern{}, err
+			}
+
+			pattern.Category = &category
+		default:
+			return project.MapPattern{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	return pattern, nil
+}
+
+// expandPath expands a leading "~" to the user's home directory and
+// environment variables. Variables are looked up case insensitive, because
+// viper lowercases all config keys. Unless bracedOnly is set, $VAR is expanded
+// in addition to ${VAR}. Expanded values are escaped with quote.
+func expandPath(s string, bracedOnly bool, quote func(string) string) (string, error) {
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed getting user's home directory: %s", err)
+		}
+
+		s = quote(filepath.ToSlash(home)) + s[1:]
+	}
+
+	var expr = envVarRegex
+	if bracedOnly {
+		expr = envVarBracedRegex
+	}
+
+	return expr.ReplaceAllStringFunc(s, func(match string) string {
+		name := strings.Trim(match, "${}")
+
+		for _, env := range os.Environ() {
+			kv := strings.SplitN(env, "=", 2)
+			if strings.EqualFold(kv[0], name) {
+				return quote(kv[1])
+			}
+		}
+
+		return ""
+	}), nil
+}
+
 func loadSanitizeParams(v *viper.Viper) (SanitizeParams, error) {
 	var params SanitizeParams
 
@@ -313,11 +1005,134 @@ func loadSanitizeParams(v *viper.Viper) (SanitizeParams, error) {
 
 	params.HideFileNames = hideFileNamesPatterns
 
+	// hide domain names
+	hideDomainNamesStr, _ := vipertools.FirstNonEmptyString(
+		v,
+		"hide-domain-names",
+		"settings.hide_domain_names",
+	)
+
+	hideDomainNamesPatterns, err := parseBoolOrRegexList(hideDomainNamesStr)
+	if err != nil {
+		return SanitizeParams{}, fmt.Errorf(
+			"failed to parse regex hide domain names param %q: %s",
+			hideDomainNamesStr,
+			err,
+		)
+	}
+
+	params.HideDomainNames = hideDomainNamesPatterns
+
+	urlMode, err := heartbeat.ParseURLMode(strings.TrimSpace(v.GetString("settings.hide_url_mode")))
+	if err != nil {
+		return SanitizeParams{}, fmt.Errorf("failed to parse hide_url_mode: %s", err)
+	}
+
+	params.URLMode = urlMode
+
+	if secretURLParams := strings.Fields(v.GetString("settings.hide_url_params")); len(secretURLParams) > 0 {
+		params.SecretURLParams = secretURLParams
+	}
+
+	fileNameHashKey, err := loadFileNameHashKey(v)
+	if err != nil {
+		return SanitizeParams{}, err
+	}
+
+	params.FileNameHashKey = fileNameHashKey
+
+	params.HideProjectFolder = vipertools.FirstNonEmptyBool(v, "hide-project-folder", "settings.hide_project_folder")
+
+	secretAction, err := secret.ParseAction(strings.TrimSpace(v.GetString("settings.secret_scanning")))
+	if err != nil {
+		return SanitizeParams{}, fmt.Errorf("failed to parse secret_scanning: %s", err)
+	}
+
+	params.SecretAction = secretAction
+	params.SecretEntropy = v.GetBool("settings.secret_scanning_entropy")
+
+	if key := strings.TrimSpace(v.GetString("settings.hide_file_names_key")); key != "" {
+		params.RuleHashKey = []byte(key)
+	}
+
+	rules, err := loadSanitizeRules(v)
+	if err != nil {
+		return SanitizeParams{}, err
+	}
+
+	for _, rule := range rules {
+		if len(rule.Hash) > 0 && len(params.RuleHashKey) == 0 {
+			return SanitizeParams{}, fmt.Errorf(
+				"sanitize rule %q hashes fields, but settings.hide_file_names_key is missing."+
+					" run with --setup to generate it",
+				rule.Rule,
+			)
+		}
+	}
+
+	params.Rules = rules
+
 	return params, nil
 }
 
-func parseBoolOrRegexList(s string) ([]*regexp.Regexp, error) {
-	var patterns []*regexp.Regexp
+// loadSanitizeRules loads the rules from the [sanitize] section. viper does not
+// preserve the order of config keys, so rules are a single multi-line value,
+// one rule per line:
+//
+//	[sanitize]
+//	rules = """project secret-.* | drop=lineno,cursorpos,lines
+//	file glob:**/*.env | hash=entity | drop=dependencies"""
+//
+// Hashing uses settings.hide_file_names_key independent of settings.hide_file_names_mode.
+func loadSanitizeRules(v *viper.Viper) ([]heartbeat.SanitizeRule, error) {
+	value := vipertools.GetStringMapString(v, "sanitize")["rules"]
+
+	var rules []heartbeat.SanitizeRule
+
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		rule, err := heartbeat.ParseSanitizeRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sanitize rule: %s", err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// loadFileNameHashKey returns the key to hash hidden file names with, if
+// settings.hide_file_names_mode is "hash". The key is read from
+// settings.hide_file_names_key, which is generated by --setup and can be
+// shared across machines. Falls back to hiding file names as "HIDDEN", if the
+// key is missing.
+func loadFileNameHashKey(v *viper.Viper) ([]byte, error) {
+	mode := strings.ToLower(strings.TrimSpace(v.GetString("settings.hide_file_names_mode")))
+
+	switch mode {
+	case "", "hidden":
+		return nil, nil
+	case "hash":
+		break
+	default:
+		return nil, fmt.Errorf("invalid hide_file_names_mode %q. must be one of hidden, hash", mode)
+	}
+
+	key := strings.TrimSpace(v.GetString("settings.hide_file_names_key"))
+	if key == "" {
+		jww.WARN.Println("missing hide_file_names_key. run with --setup to generate it. hiding file names instead")
+		return nil, nil
+	}
+
+	return []byte(key), nil
+}
+
+func parseBoolOrRegexList(s string) ([]matcher.Matcher, error) {
+	var patterns []matcher.Matcher
 
 	switch {
 	case s == "":
@@ -325,13 +1140,13 @@ func parseBoolOrRegexList(s string) ([]*regexp.Regexp, error) {
 	case strings.ToLower(s) == "false":
 		break
 	case strings.ToLower(s) == "true":
-		patterns = []*regexp.Regexp{matchAllRegex}
+		patterns = []matcher.Matcher{matchAllRegex}
 	default:
 		splitted := strings.Split(s, "\n")
 		for _, s := range splitted {
-			compiled, err := regexp.Compile(s)
+			compiled, err := matcher.Compile(s)
 			if err != nil {
-				return nil, fmt.Errorf("failed to compile regex %q: %s", s, err)
+				return nil, err
 			}
 
 			patterns = append(patterns, compiled)
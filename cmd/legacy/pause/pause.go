@@ -0,0 +1,58 @@
+package pause
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/config"
+	"github.com/wakatime/wakatime-cli/pkg/exitcode"
+	"github.com/wakatime/wakatime-cli/pkg/pause"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Run executes the pause, resume or pause-status command.
+func Run(v *viper.Viper) {
+	output, err := Execute(v, time.Now())
+	if err != nil {
+		jww.ERROR.Printf("err: %s", err)
+		os.Exit(exitcode.ErrDefault)
+	}
+
+	fmt.Println(output)
+	os.Exit(exitcode.Success)
+}
+
+// Execute pauses tracking, if the pause flag is set, resumes tracking, if the
+// resume flag is set, and returns the resulting pause status.
+func Execute(v *viper.Viper, now time.Time) (string, error) {
+	fp, err := config.InternalFilePath()
+	if err != nil {
+		return "", fmt.Errorf("failed to get internal state file path: %s", err)
+	}
+
+	switch {
+	case v.IsSet("pause"):
+		until, err := pause.ParseUntil(v.GetString("pause"), now)
+		if err != nil {
+			return "", err
+		}
+
+		if err := pause.Save(fp, pause.State{Until: until}); err != nil {
+			return "", err
+		}
+	case v.GetBool("resume"):
+		if err := pause.Save(fp, pause.State{}); err != nil {
+			return "", err
+		}
+	}
+
+	state, err := pause.Load(fp)
+	if err != nil {
+		return "", err
+	}
+
+	return state.Status(now), nil
+}
@@ -0,0 +1,72 @@
+package pause_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/cmd/legacy/pause"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	defer os.Unsetenv("WAKATIME_HOME")
+
+	err = os.Setenv("WAKATIME_HOME", tmpDir)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	v := viper.New()
+	v.Set("pause-status", true)
+
+	output, err := pause.Execute(v, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Tracking is active", output)
+
+	v = viper.New()
+	v.Set("pause", "1h30m")
+
+	output, err = pause.Execute(v, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Tracking is paused until "+now.Add(90*time.Minute).Format("2006-01-02 15:04")+
+		" (1h30m0s remaining)", output)
+
+	v = viper.New()
+	v.Set("resume", true)
+
+	output, err = pause.Execute(v, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Tracking is active", output)
+}
+
+func TestExecute_ErrInvalidPause(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	defer os.Unsetenv("WAKATIME_HOME")
+
+	err = os.Setenv("WAKATIME_HOME", tmpDir)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.Set("pause", "forever")
+
+	_, err = pause.Execute(v, time.Now())
+	assert.EqualError(t, err,
+		`invalid pause "forever". must be a duration like 1h30m or a time like 17:30 or 2006-01-02 15:04`)
+}
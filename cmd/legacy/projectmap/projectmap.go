@@ -0,0 +1,66 @@
+package projectmap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	cmdheartbeat "github.com/wakatime/wakatime-cli/cmd/legacy/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/exitcode"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Run prints the [projectmap] rule matching the given path and the resulting
+// project, branch and category.
+func Run(v *viper.Viper) {
+	output, err := Test(v)
+	if err != nil {
+		jww.ERROR.Printf("err: %s", err)
+		os.Exit(exitcode.ErrDefault)
+	}
+
+	fmt.Println(output)
+	os.Exit(exitcode.Success)
+}
+
+// Test matches the given path against the [projectmap] rules and returns
+// a human readable description of the result.
+func Test(v *viper.Viper) (string, error) {
+	fp := strings.TrimSpace(v.GetString("project-map-test"))
+	if fp == "" {
+		return "", fmt.Errorf("path to test against project map rules must not be empty")
+	}
+
+	patterns := cmdheartbeat.LoadProjectMapPatterns(v)
+	if len(patterns) == 0 {
+		return "no [projectmap] rules configured", nil
+	}
+
+	match, ok, err := project.MatchMap(fp, patterns)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return fmt.Sprintf("no [projectmap] rule matched %q", fp), nil
+	}
+
+	lines := []string{
+		fmt.Sprintf("rule: %s", match.Pattern.Rule),
+		fmt.Sprintf("regex: %s", match.Pattern.Regex),
+		fmt.Sprintf("project: %s", match.Project),
+	}
+
+	if match.Branch != "" {
+		lines = append(lines, fmt.Sprintf("branch: %s", match.Branch))
+	}
+
+	if match.Pattern.Category != nil {
+		lines = append(lines, fmt.Sprintf("category: %s", match.Pattern.Category))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
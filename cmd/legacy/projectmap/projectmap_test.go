@@ -0,0 +1,99 @@
+package projectmap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/cmd/legacy/projectmap"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTest(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	tmpDir, err = filepath.EvalSymlinks(tmpDir)
+	require.NoError(t, err)
+
+	fp := filepath.Join(tmpDir, "acme", "main.go")
+
+	err = os.MkdirAll(filepath.Dir(fp), 0700)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(fp, nil, 0600)
+	require.NoError(t, err)
+
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err = v.ReadConfig(strings.NewReader(`[projectmap]
+"glob:` + tmpDir + `/*/" = client-{0} | branch=main | category=code reviewing
+`))
+	require.NoError(t, err)
+
+	v.Set("project-map-test", fp)
+
+	output, err := projectmap.Test(v)
+	require.NoError(t, err)
+
+	lines := strings.Split(output, "\n")
+	require.Len(t, lines, 5)
+
+	assert.Equal(
+		t,
+		"rule: glob:"+strings.ToLower(tmpDir)+"/*/ = client-{0} | branch=main | category=code reviewing",
+		lines[0],
+	)
+	assert.Equal(t, "project: client-acme", lines[2])
+	assert.Equal(t, "branch: main", lines[3])
+	assert.Equal(t, "category: code reviewing", lines[4])
+}
+
+func TestTest_NonExistingPath(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err := v.ReadConfig(strings.NewReader(`[projectmap]
+^/nonexisting/(\w+)/ = client-{0}
+`))
+	require.NoError(t, err)
+
+	v.Set("project-map-test", "/nonexisting/acme/../billing/main.go")
+
+	output, err := projectmap.Test(v)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "project: client-billing")
+}
+
+func TestTest_NoMatch(t *testing.T) {
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.Remove(tmpFile.Name())
+
+	v := viper.New()
+	v.Set("projectmap./nonexisting/path", "other")
+	v.Set("project-map-test", tmpFile.Name())
+
+	output, err := projectmap.Test(v)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "no [projectmap] rule matched")
+}
+
+func TestTest_EmptyPath(t *testing.T) {
+	v := viper.New()
+	v.Set("project-map-test", " ")
+
+	_, err := projectmap.Test(v)
+	assert.Error(t, err)
+}
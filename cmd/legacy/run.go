@@ -5,10 +5,15 @@ import (
 	"os"
 
 	"github.com/wakatime/wakatime-cli/cmd/legacy/configread"
+	"github.com/wakatime/wakatime-cli/cmd/legacy/configvalidate"
 	"github.com/wakatime/wakatime-cli/cmd/legacy/configwrite"
 	"github.com/wakatime/wakatime-cli/cmd/legacy/heartbeat"
 	"github.com/wakatime/wakatime-cli/cmd/legacy/logfile"
+	"github.com/wakatime/wakatime-cli/cmd/legacy/pause"
+	"github.com/wakatime/wakatime-cli/cmd/legacy/projectmap"
+	"github.com/wakatime/wakatime-cli/cmd/legacy/setup"
 	"github.com/wakatime/wakatime-cli/cmd/legacy/today"
+	"github.com/wakatime/wakatime-cli/cmd/legacy/validateapikey"
 	"github.com/wakatime/wakatime-cli/pkg/config"
 	"github.com/wakatime/wakatime-cli/pkg/exitcode"
 
@@ -28,6 +33,20 @@ func Run(v *viper.Viper) {
 		os.Exit(exitcode.Success)
 	}
 
+	// validation reports problems with line numbers, even if the config file cannot be parsed
+	if v.GetBool("config-validate") {
+		jww.DEBUG.Println("command: config-validate")
+
+		configvalidate.Run(v)
+	}
+
+	// setup creates the config file, if missing, so it runs before reading it
+	if v.GetBool("setup") {
+		jww.DEBUG.Println("command: setup")
+
+		setup.Run(v)
+	}
+
 	if err := config.ReadInConfig(v, config.FilePath); err != nil {
 		jww.CRITICAL.Printf("err: %s", err)
 
@@ -51,12 +70,36 @@ func Run(v *viper.Viper) {
 		configwrite.Run(v)
 	}
 
+	if v.IsSet("project-map-test") {
+		jww.DEBUG.Println("command: project-map-test")
+
+		projectmap.Run(v)
+	}
+
+	if v.IsSet("pause") || v.GetBool("resume") || v.GetBool("pause-status") {
+		jww.DEBUG.Println("command: pause")
+
+		pause.Run(v)
+	}
+
 	if v.GetBool("today") {
 		jww.DEBUG.Println("command: today")
 
 		today.Run(v)
 	}
 
+	if v.GetBool("validate-api-key") {
+		jww.DEBUG.Println("command: validate-api-key")
+
+		validateapikey.Run(v)
+	}
+
+	if v.GetBool("dry-run") {
+		jww.DEBUG.Println("command: heartbeat dry-run")
+
+		heartbeat.RunDryRun(v)
+	}
+
 	jww.DEBUG.Println("command: heartbeat")
 
 	heartbeat.Run(v)
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package setup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// disableEcho disables echoing of input typed into the terminal f. The
+// returned function restores echoing.
+func disableEcho(f *os.File) (func(), error) {
+	if err := stty(f, "-echo"); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = stty(f, "echo")
+	}, nil
+}
+
+// stty runs stty with arg on the terminal f.
+func stty(f *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = f
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run stty %s: %s: %s", arg, err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package setup
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// disableEcho disables echoing of input typed into the console f. The
+// returned function restores the previous console mode.
+func disableEcho(f *os.File) (func(), error) {
+	handle := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return nil, fmt.Errorf("failed to get console mode: %s", err)
+	}
+
+	if err := windows.SetConsoleMode(handle, mode&^windows.ENABLE_ECHO_INPUT); err != nil {
+		return nil, fmt.Errorf("failed to set console mode: %s", err)
+	}
+
+	return func() {
+		_ = windows.SetConsoleMode(handle, mode)
+	}, nil
+}
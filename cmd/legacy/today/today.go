@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"time"
 
 	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/config"
 	"github.com/wakatime/wakatime-cli/pkg/exitcode"
+	"github.com/wakatime/wakatime-cli/pkg/pause"
 	"github.com/wakatime/wakatime-cli/pkg/summary"
 	"github.com/wakatime/wakatime-cli/pkg/vipertools"
 
@@ -17,13 +18,6 @@ import (
 	"github.com/spf13/viper"
 )
 
-var (
-	// nolint
-	apiKeyRegex = regexp.MustCompile("^[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89ab][a-f0-9]{3}-[a-f0-9]{12}$")
-	// nolint
-	proxyRegex = regexp.MustCompile(`^((https?|socks5)://)?([^:@]+(:([^:@])+)?@)?[^:]+(:\d+)?$`)
-)
-
 // Params contains today command parameters.
 type Params struct {
 	APIKey  string
@@ -74,11 +68,51 @@ func Summary(v *viper.Viper) (string, error) {
 		return "", fmt.Errorf("failed to load command parameters: %w", err)
 	}
 
+	c, err := NewClient(params)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	todayEnd := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, int(time.Second-time.Nanosecond), now.Location())
+
+	summaries, err := c.Summaries(todayStart, todayEnd)
+	if err != nil {
+		return "", fmt.Errorf("failed fetching summaries from api: %w", err)
+	}
+
+	output, err := summary.RenderToday(summaries)
+	if err != nil {
+		return "", fmt.Errorf("failed generating today summary output: %s", err)
+	}
+
+	internalFilepath, err := config.InternalFilePath()
+	if err != nil {
+		jww.WARN.Printf("failed to get internal state file path: %s", err)
+		return output, nil
+	}
+
+	state, err := pause.Load(internalFilepath)
+	if err != nil {
+		jww.WARN.Printf("failed to load pause state: %s", err)
+		return output, nil
+	}
+
+	if state.Paused(now) {
+		output += "\n" + state.Status(now)
+	}
+
+	return output, nil
+}
+
+// NewClient creates an api client from command parameters.
+func NewClient(params Params) (*api.Client, error) {
 	auth, err := api.WithAuth(api.BasicAuth{
 		Secret: params.APIKey,
 	})
 	if err != nil {
-		return "", fmt.Errorf("error setting up auth option on api client: %w", err)
+		return nil, fmt.Errorf("error setting up auth option on api client: %w", err)
 	}
 
 	opts := []api.Option{
@@ -93,7 +127,7 @@ func Summary(v *viper.Viper) (string, error) {
 	if params.Network.ProxyURL != "" {
 		withProxy, err := api.WithProxy(params.Network.ProxyURL)
 		if err != nil {
-			return "", fmt.Errorf("failed to set up proxy option on api client: %w", err)
+			return nil, fmt.Errorf("failed to set up proxy option on api client: %w", err)
 		}
 
 		opts = append(opts, withProxy)
@@ -102,7 +136,7 @@ func Summary(v *viper.Viper) (string, error) {
 	if params.Network.SSLCertFilepath != "" {
 		withSSLCert, err := api.WithSSLCert(params.Network.SSLCertFilepath)
 		if err != nil {
-			return "", fmt.Errorf("failed to set up ssl cert option on api client: %w", err)
+			return nil, fmt.Errorf("failed to set up ssl cert option on api client: %w", err)
 		}
 
 		opts = append(opts, withSSLCert)
@@ -119,35 +153,19 @@ func Summary(v *viper.Viper) (string, error) {
 		url = params.APIUrl
 	}
 
-	c := api.NewClient(url, http.DefaultClient, opts...)
-
-	now := time.Now()
-	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	todayEnd := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, int(time.Second-time.Nanosecond), now.Location())
-
-	summaries, err := c.Summaries(todayStart, todayEnd)
-	if err != nil {
-		return "", fmt.Errorf("failed fetching summaries from api: %w", err)
-	}
-
-	output, err := summary.RenderToday(summaries)
-	if err != nil {
-		return "", fmt.Errorf("failed generating today summary output: %s", err)
-	}
-
-	return output, nil
+	return api.NewClient(url, http.DefaultClient, opts...), nil
 }
 
 // LoadParams loads today config params from viper.Viper instance. Returns ErrAuth
 // if failed to retrieve api key.
 func LoadParams(v *viper.Viper) (Params, error) {
-	apiKey, ok := vipertools.FirstNonEmptyString(v, "key", "settings.api_key", "settings.apikey")
-	if !ok {
-		return Params{}, api.ErrAuth("failed to load api key")
+	apiKey, err := config.APIKey(v)
+	if err != nil {
+		return Params{}, api.ErrAuth(fmt.Sprintf("failed to load api key: %s", err))
 	}
 
-	if !apiKeyRegex.Match([]byte(apiKey)) {
-		return Params{}, api.ErrAuth("api key invalid")
+	if apiKey == "" {
+		return Params{}, api.ErrAuth("failed to load api key")
 	}
 
 	params := Params{
@@ -160,6 +178,15 @@ func LoadParams(v *viper.Viper) (Params, error) {
 		params.APIUrl = apiURL
 	}
 
+	keyFormats, err := config.APIKeyFormats(v)
+	if err != nil {
+		return Params{}, err
+	}
+
+	if err := api.ValidateKey(apiKey, api.KeyFormats(params.APIUrl, keyFormats...)); err != nil {
+		return Params{}, err
+	}
+
 	timeoutSecs, ok := vipertools.FirstNonEmptyInt(v, "timeout", "settings.timeout")
 	if ok {
 		params.Timeout = time.Duration(timeoutSecs) * time.Second
@@ -186,7 +213,7 @@ func loadNetworkParams(v *viper.Viper) (NetworkParams, error) {
 		"'domain\\user:pass.'"
 
 	proxyURL, _ := vipertools.FirstNonEmptyString(v, "proxy", "settings.proxy")
-	if proxyURL != "" && !proxyRegex.MatchString(proxyURL) {
+	if proxyURL != "" && api.ValidateProxyURL(proxyURL) != nil {
 		return NetworkParams{}, fmt.Errorf(errMsgTemplate, proxyURL)
 	}
 
@@ -7,12 +7,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/wakatime/wakatime-cli/cmd/legacy/today"
 	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/pause"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -70,6 +73,49 @@ func TestSummary(t *testing.T) {
 	assert.Eventually(t, func() bool { return numCalls == 1 }, time.Second, 50*time.Millisecond)
 }
 
+func TestSummary_Paused(t *testing.T) {
+	testServerURL, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	defer os.Unsetenv("WAKATIME_HOME")
+
+	err = os.Setenv("WAKATIME_HOME", tmpDir)
+	require.NoError(t, err)
+
+	until := time.Now().Add(time.Hour)
+
+	err = pause.Save(filepath.Join(tmpDir, ".wakatime-internal.cfg"), pause.State{Until: until})
+	require.NoError(t, err)
+
+	dateToday := time.Now().Format("2006-01-02")
+
+	router.HandleFunc("/v1/users/current/summaries", func(w http.ResponseWriter, req *http.Request) {
+		data, err := ioutil.ReadFile("testdata/api_summaries_response_template.json")
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(fmt.Sprintf(string(data), dateToday)))
+		require.NoError(t, err)
+	})
+
+	v := viper.New()
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("api-url", testServerURL)
+
+	output, err := today.Summary(v)
+	require.NoError(t, err)
+
+	lines := strings.Split(output, "\n")
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "10 secs", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "Tracking is paused until "+until.Format("2006-01-02 15:04")))
+}
+
 func TestSummary_ErrApi(t *testing.T) {
 	testServerURL, router, tearDown := setupTestServer()
 	defer tearDown()
@@ -0,0 +1,68 @@
+package validateapikey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/wakatime/wakatime-cli/cmd/legacy/today"
+	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/exitcode"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+// Run validates the api key against the api and prints the result.
+// Exits with exitcode.ErrAuth for an invalid api key, exitcode.ErrConnection
+// if the api could not be reached and exitcode.ErrAPI for other api errors.
+func Run(v *viper.Viper) {
+	output, err := Validate(v)
+	if err != nil {
+		var errauth api.ErrAuth
+		if errors.As(err, &errauth) {
+			jww.CRITICAL.Printf(
+				"invalid api key: %s. Find your api key from wakatime.com/settings/api-key",
+				errauth,
+			)
+			os.Exit(exitcode.ErrAuth)
+		}
+
+		var errrequest api.ErrRequest
+		if errors.As(err, &errrequest) {
+			jww.CRITICAL.Printf("failed to reach api: %s", errrequest)
+			os.Exit(exitcode.ErrConnection)
+		}
+
+		var errapi api.Err
+		if errors.As(err, &errapi) {
+			jww.CRITICAL.Println(err)
+			os.Exit(exitcode.ErrAPI)
+		}
+
+		jww.CRITICAL.Println(err)
+		os.Exit(exitcode.ErrDefault)
+	}
+
+	fmt.Println(output)
+	os.Exit(exitcode.Success)
+}
+
+// Validate checks the format of the api key and validates it against the api.
+func Validate(v *viper.Viper) (string, error) {
+	params, err := today.LoadParams(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to load command parameters: %w", err)
+	}
+
+	c, err := today.NewClient(params)
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.ValidateKeyRemote(); err != nil {
+		return "", fmt.Errorf("failed to validate api key: %w", err)
+	}
+
+	return "api key is valid", nil
+}
@@ -0,0 +1,93 @@
+package validateapikey_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/cmd/legacy/validateapikey"
+	"github.com/wakatime/wakatime-cli/pkg/api"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testServerURL, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	var numCalls int
+
+	router.HandleFunc("/v1/users/current", func(w http.ResponseWriter, req *http.Request) {
+		numCalls++
+
+		// self-hosted key
+		assert.Equal(t, []string{"Basic OGY5ZTJkMWM0YjZh"}, req.Header["Authorization"])
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	v := viper.New()
+	v.Set("key", "8f9e2d1c4b6a")
+	v.Set("api-url", testServerURL)
+
+	output, err := validateapikey.Validate(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "api key is valid", output)
+	assert.Equal(t, 1, numCalls)
+}
+
+func TestValidate_ErrAuth(t *testing.T) {
+	testServerURL, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	router.HandleFunc("/v1/users/current", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	v := viper.New()
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("api-url", testServerURL)
+
+	_, err := validateapikey.Validate(v)
+	require.Error(t, err)
+
+	var errauth api.ErrAuth
+	assert.True(t, errors.As(err, &errauth))
+}
+
+func TestValidate_ErrAuth_InvalidFormat(t *testing.T) {
+	v := viper.New()
+	v.Set("key", "not-an-api-key")
+
+	_, err := validateapikey.Validate(v)
+	require.Error(t, err)
+
+	var errauth api.ErrAuth
+	assert.True(t, errors.As(err, &errauth))
+}
+
+func TestValidate_ErrRequest(t *testing.T) {
+	testServerURL, _, tearDown := setupTestServer()
+	tearDown()
+
+	v := viper.New()
+	v.Set("key", "00000000-0000-4000-8000-000000000000")
+	v.Set("api-url", testServerURL)
+
+	_, err := validateapikey.Validate(v)
+	require.Error(t, err)
+
+	var errrequest api.ErrRequest
+	assert.True(t, errors.As(err, &errrequest))
+}
+
+func setupTestServer() (string, *http.ServeMux, func()) {
+	router := http.NewServeMux()
+	srv := httptest.NewServer(router)
+
+	return srv.URL, router, func() { srv.Close() }
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// KeyFormat is an accepted api key format.
+type KeyFormat struct {
+	Name  string
+	Regex *regexp.Regexp
+}
+
+// nolint
+var (
+	// KeyFormatUUID is the classic wakatime api key, a uuid version 4.
+	KeyFormatUUID = KeyFormat{
+		Name:  "uuid",
+		Regex: regexp.MustCompile("^[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89ab][a-f0-9]{3}-[a-f0-9]{12}$"),
+	}
+	// KeyFormatWaka is a uuid version 4 with "waka_" prefix.
+	KeyFormatWaka = KeyFormat{
+		Name:  "waka",
+		Regex: regexp.MustCompile("^waka_[a-f0-9]{8}-[a-f0-9]{4}-4[a-f0-9]{3}-[89ab][a-f0-9]{3}-[a-f0-9]{12}$"),
+	}
+	// KeyFormatSelfHosted accepts any printable key without whitespace, as
+	// self-hosted servers issue keys of their own format.
+	KeyFormatSelfHosted = KeyFormat{
+		Name:  "self-hosted",
+		Regex: regexp.MustCompile(`^[\x21-\x7e]+$`),
+	}
+)
+
+// DefaultKeyFormats are the api key formats accepted by the wakatime api.
+// nolint
+var DefaultKeyFormats = []KeyFormat{KeyFormatUUID, KeyFormatWaka}
+
+// NewKeyFormat creates a KeyFormat from a regular expression, e.g. to accept
+// keys of a custom format configured via settings.api_key_pattern.
+func NewKeyFormat(name, pattern string) (KeyFormat, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return KeyFormat{}, fmt.Errorf("failed to compile api key pattern %q: %s", pattern, err)
+	}
+
+	return KeyFormat{
+		Name:  name,
+		Regex: regex,
+	}, nil
+}
+
+// KeyFormats returns the api key formats accepted for apiURL and the extra
+// formats. Keys of any format are accepted for api urls other than wakatime.com.
+func KeyFormats(apiURL string, extra ...KeyFormat) []KeyFormat {
+	formats := append(append([]KeyFormat{}, DefaultKeyFormats...), extra...)
+
+	if IsSelfHosted(apiURL) {
+		formats = append(formats, KeyFormatSelfHosted)
+	}
+
+	return formats
+}
+
+// IsSelfHosted checks if apiURL points to a server other than wakatime.com.
+// An empty api url defaults to wakatime.com.
+func IsSelfHosted(apiURL string) bool {
+	if apiURL == "" {
+		return false
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	return host != "wakatime.com" && !strings.HasSuffix(host, ".wakatime.com")
+}
+
+// ValidateKey checks if key matches one of formats. Returns ErrAuth otherwise.
+func ValidateKey(key string, formats []KeyFormat) error {
+	for _, format := range formats {
+		if format.Regex.MatchString(key) {
+			return nil
+		}
+	}
+
+	return ErrAuth("invalid api key format")
+}
+
+// ValidateKeyRemote checks the api key of the client by requesting the current
+// user from the api. Returns ErrAuth for an invalid api key, ErrRequest if the
+// api could not be reached and Err for any other api error.
+func (c *Client) ValidateKeyRemote() error {
+	url := c.baseURL + "/v1/users/current"
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return ErrRequest(fmt.Sprintf("failed to make request to %q: %s", url, err))
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ErrRequest(fmt.Sprintf("failed to read response body from %q: %s", url, err))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuth(fmt.Sprintf("authentication failed at %q. body: %q", url, string(body)))
+	default:
+		return Err(fmt.Sprintf(
+			"invalid response status from %q. got: %d, want: %d. body: %q",
+			url,
+			resp.StatusCode,
+			http.StatusOK,
+			string(body),
+		))
+	}
+}
@@ -0,0 +1,185 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKey(t *testing.T) {
+	custom, err := api.NewKeyFormat("custom", "^team-[0-9]+$")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		Key     string
+		APIUrl  string
+		Extra   []api.KeyFormat
+		IsValid bool
+	}{
+		"uuid": {
+			Key:     "00000000-0000-4000-8000-000000000000",
+			IsValid: true,
+		},
+		"waka prefix": {
+			Key:     "waka_00000000-0000-4000-8000-000000000000",
+			IsValid: true,
+		},
+		"waka prefix invalid uuid": {
+			Key:     "waka_00000000-0000-0000-8000-000000000000",
+			IsValid: false,
+		},
+		"uuid version 1": {
+			Key:     "00000000-0000-1000-8000-000000000000",
+			IsValid: false,
+		},
+		"opaque key at wakatime.com": {
+			Key:     "8f9e2d1c4b6a",
+			APIUrl:  api.BaseURL,
+			IsValid: false,
+		},
+		"opaque key at self-hosted server": {
+			Key:     "8f9e2d1c4b6a",
+			APIUrl:  "https://wakapi.example.com/api",
+			IsValid: true,
+		},
+		"whitespace at self-hosted server": {
+			Key:     "8f9e 2d1c",
+			APIUrl:  "https://wakapi.example.com/api",
+			IsValid: false,
+		},
+		"custom format": {
+			Key:     "team-42",
+			Extra:   []api.KeyFormat{custom},
+			IsValid: true,
+		},
+		"empty": {
+			Key:     "",
+			APIUrl:  "https://wakapi.example.com/api",
+			IsValid: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := api.ValidateKey(test.Key, api.KeyFormats(test.APIUrl, test.Extra...))
+			if test.IsValid {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errauth api.ErrAuth
+
+			require.Error(t, err)
+			assert.True(t, errors.As(err, &errauth))
+		})
+	}
+}
+
+func TestIsSelfHosted(t *testing.T) {
+	tests := map[string]bool{
+		"":                               false,
+		"https://api.wakatime.com/api":   false,
+		"https://WakaTime.com/api":       false,
+		"https://wakapi.example.com/api": true,
+		"http://localhost:3000/api":      true,
+		"https://notwakatime.com/api":    true,
+	}
+
+	for apiURL, expected := range tests {
+		t.Run(apiURL, func(t *testing.T) {
+			assert.Equal(t, expected, api.IsSelfHosted(apiURL))
+		})
+	}
+}
+
+func TestNewKeyFormat_Err(t *testing.T) {
+	_, err := api.NewKeyFormat("custom", "(")
+	require.Error(t, err)
+}
+
+func TestClient_ValidateKeyRemote(t *testing.T) {
+	u, router, tearDown := setupTestServer()
+	defer tearDown()
+
+	var numCalls int
+
+	router.HandleFunc("/v1/users/current", func(w http.ResponseWriter, req *http.Request) {
+		numCalls++
+
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Equal(t, []string{"Basic MDAwMDAwMDAtMDAwMC00MDAwLTgwMDAtMDAwMDAwMDAwMDAw"}, req.Header["Authorization"])
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	withAuth, err := api.WithAuth(api.BasicAuth{Secret: "00000000-0000-4000-8000-000000000000"})
+	require.NoError(t, err)
+
+	c := api.NewClient(u, http.DefaultClient, withAuth)
+
+	err = c.ValidateKeyRemote()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, numCalls)
+}
+
+func TestClient_ValidateKeyRemote_Err(t *testing.T) {
+	var (
+		errauth api.ErrAuth
+		errapi  api.Err
+	)
+
+	tests := map[string]struct {
+		Status   int
+		Expected interface{}
+	}{
+		"unauthorized": {
+			Status:   http.StatusUnauthorized,
+			Expected: &errauth,
+		},
+		"forbidden": {
+			Status:   http.StatusForbidden,
+			Expected: &errauth,
+		},
+		"server error": {
+			Status:   http.StatusInternalServerError,
+			Expected: &errapi,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, router, tearDown := setupTestServer()
+			defer tearDown()
+
+			router.HandleFunc("/v1/users/current", func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(test.Status)
+			})
+
+			c := api.NewClient(u, http.DefaultClient)
+
+			err := c.ValidateKeyRemote()
+			require.Error(t, err)
+
+			assert.True(t, errors.As(err, test.Expected))
+		})
+	}
+}
+
+func TestClient_ValidateKeyRemote_ErrRequest(t *testing.T) {
+	u, _, tearDown := setupTestServer()
+	tearDown()
+
+	c := api.NewClient(u, http.DefaultClient)
+
+	err := c.ValidateKeyRemote()
+	require.Error(t, err)
+
+	var errrequest api.ErrRequest
+	assert.True(t, errors.As(err, &errrequest))
+}
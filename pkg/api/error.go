@@ -15,3 +15,11 @@ type ErrAuth string
 func (e ErrAuth) Error() string {
 	return string(e)
 }
+
+// ErrRequest represents a failure to reach the api, e.g. because of network errors.
+type ErrRequest string
+
+// Error method to implement error interface.
+func (e ErrRequest) Error() string {
+	return string(e)
+}
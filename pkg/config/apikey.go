@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/vipertools"
+
+	"github.com/mitchellh/go-homedir"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+)
+
+const (
+	// APIKeyEnvVar is the environment variable to read the api key from.
+	APIKeyEnvVar = "WAKATIME_API_KEY"
+	// defaultVaultCmdTimeout is the default time to wait for api_key_vault_cmd.
+	defaultVaultCmdTimeout = 10 * time.Second
+)
+
+// apiKeyCache caches api keys read from files and commands per source for
+// the life of the process, so that e.g. a password manager is asked only once.
+// nolint
+var apiKeyCache = struct {
+	sync.Mutex
+	values map[string]string
+}{
+	values: make(map[string]string),
+}
+
+// APIKey returns the api key from the first non-empty source of:
+//
+//  1. --key flag
+//  2. WAKATIME_API_KEY environment variable
+//  3. settings.api_key or the deprecated settings.apikey
+//  4. file at settings.api_key_file
+//  5. stdout of settings.api_key_vault_cmd
+//
+// Later sources are only read, if earlier ones are empty. Returns an empty
+// string without error, if no source contains an api key.
+func APIKey(v *viper.Viper) (string, error) {
+	if apiKey, ok := vipertools.FirstNonEmptyString(v, "key"); ok {
+		return apiKey, nil
+	}
+
+	if apiKey := strings.TrimSpace(os.Getenv(APIKeyEnvVar)); apiKey != "" {
+		return apiKey, nil
+	}
+
+	if apiKey, ok := vipertools.FirstNonEmptyString(v, "settings.api_key", "settings.apikey"); ok {
+		return apiKey, nil
+	}
+
+	if fp := strings.TrimSpace(v.GetString("settings.api_key_file")); fp != "" {
+		return cachedAPIKey("file:"+fp, func() (string, error) {
+			return apiKeyFromFile(fp)
+		})
+	}
+
+	if command := strings.TrimSpace(v.GetString("settings.api_key_vault_cmd")); command != "" {
+		timeout := defaultVaultCmdTimeout
+		if seconds := v.GetInt("settings.api_key_vault_timeout"); seconds > 0 {
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		return cachedAPIKey("cmd:"+command, func() (string, error) {
+			return apiKeyFromCommand(command, timeout)
+		})
+	}
+
+	return "", nil
+}
+
+// APIKeyFormats returns the custom api key format from settings.api_key_pattern,
+// accepted in addition to the default formats of api.KeyFormats.
+func APIKeyFormats(v *viper.Viper) ([]api.KeyFormat, error) {
+	pattern := strings.TrimSpace(v.GetString("settings.api_key_pattern"))
+	if pattern == "" {
+		return nil, nil
+	}
+
+	format, err := api.NewKeyFormat("custom", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load api_key_pattern: %s", err)
+	}
+
+	return []api.KeyFormat{format}, nil
+}
+
+// cachedAPIKey returns the cached api key of source. Otherwise calls load and
+// caches its result on success.
+func cachedAPIKey(source string, load func() (string, error)) (string, error) {
+	apiKeyCache.Lock()
+	defer apiKeyCache.Unlock()
+
+	if apiKey, ok := apiKeyCache.values[source]; ok {
+		return apiKey, nil
+	}
+
+	apiKey, err := load()
+	if err != nil {
+		return "", err
+	}
+
+	apiKeyCache.values[source] = apiKey
+
+	return apiKey, nil
+}
+
+// apiKeyFromFile reads the api key from the file at fp. A leading "~" expands
+// to the user's home directory.
+func apiKeyFromFile(fp string) (string, error) {
+	expanded, err := homedir.Expand(fp)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand api_key_file path %q: %s", fp, err)
+	}
+
+	data, err := ioutil.ReadFile(expanded) // nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to read api_key_file: %s", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// apiKeyFromCommand runs command in a shell and reads the api key from the first
+// line of its stdout, like printed by `pass show wakatime`. The command is
+// killed after timeout.
+func apiKeyFromCommand(command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command) // nolint:gosec
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command) // nolint:gosec
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	jww.DEBUG.Printf("reading api key from api_key_vault_cmd")
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start api_key_vault_cmd: %s", err)
+	}
+
+	// children of the shell may keep stdout open after it got killed, so
+	// do not wait for the command to finish after the timeout
+	done := make(chan error, 1)
+
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("api_key_vault_cmd timed out after %s", timeout)
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("failed to run api_key_vault_cmd: %s: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	line := strings.SplitN(strings.TrimSpace(stdout.String()), "\n", 2)[0]
+
+	return strings.TrimSpace(line), nil
+}
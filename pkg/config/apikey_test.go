@@ -0,0 +1,155 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/config"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime-apikey")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, "apikey")
+
+	err = ioutil.WriteFile(fp, []byte("00000000-0000-4000-8000-000000000004\n"), 0600)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		Flag     string
+		Env      string
+		Config   string
+		File     string
+		Expected string
+	}{
+		"flag takes precedence": {
+			Flag:     "00000000-0000-4000-8000-000000000001",
+			Env:      "00000000-0000-4000-8000-000000000002",
+			Config:   "00000000-0000-4000-8000-000000000003",
+			File:     fp,
+			Expected: "00000000-0000-4000-8000-000000000001",
+		},
+		"env var takes precedence over config": {
+			Env:      "00000000-0000-4000-8000-000000000002",
+			Config:   "00000000-0000-4000-8000-000000000003",
+			File:     fp,
+			Expected: "00000000-0000-4000-8000-000000000002",
+		},
+		"config takes precedence over file": {
+			Config:   "00000000-0000-4000-8000-000000000003",
+			File:     fp,
+			Expected: "00000000-0000-4000-8000-000000000003",
+		},
+		"file": {
+			File:     fp,
+			Expected: "00000000-0000-4000-8000-000000000004",
+		},
+		"unset": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv(config.APIKeyEnvVar, test.Env)
+			defer os.Unsetenv(config.APIKeyEnvVar)
+
+			v := viper.New()
+			v.Set("key", test.Flag)
+			v.Set("settings.api_key", test.Config)
+			v.Set("settings.api_key_file", test.File)
+
+			apiKey, err := config.APIKey(v)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, apiKey)
+		})
+	}
+}
+
+func TestAPIKey_FileErr(t *testing.T) {
+	v := viper.New()
+	v.Set("settings.api_key_file", "testdata/nonexisting")
+
+	_, err := config.APIKey(v)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "failed to read api_key_file")
+}
+
+func TestAPIKey_VaultCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell command")
+	}
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime-apikey")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	counter := filepath.Join(tmpDir, "counter")
+
+	v := viper.New()
+	v.Set("settings.api_key_vault_cmd", fmt.Sprintf(
+		"echo x >> %s; printf '00000000-0000-4000-8000-000000000005\\nlogin: user\\n'",
+		counter,
+	))
+
+	apiKey, err := config.APIKey(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "00000000-0000-4000-8000-000000000005", apiKey)
+
+	// cached for the life of the process
+	apiKey, err = config.APIKey(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "00000000-0000-4000-8000-000000000005", apiKey)
+
+	data, err := ioutil.ReadFile(counter)
+	require.NoError(t, err)
+
+	assert.Equal(t, "x\n", string(data))
+}
+
+func TestAPIKey_VaultCmd_Err(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell command")
+	}
+
+	v := viper.New()
+	v.Set("settings.api_key_vault_cmd", "echo locked >&2; exit 1")
+
+	_, err := config.APIKey(v)
+	require.Error(t, err)
+
+	assert.EqualError(t, err, "failed to run api_key_vault_cmd: exit status 1: locked")
+}
+
+func TestAPIKey_VaultCmd_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell command")
+	}
+
+	v := viper.New()
+	v.Set("settings.api_key_vault_cmd", "sleep 5 | cat")
+	v.Set("settings.api_key_vault_timeout", 1)
+
+	start := time.Now()
+
+	_, err := config.APIKey(v)
+	require.Error(t, err)
+
+	assert.EqualError(t, err, "api_key_vault_cmd timed out after 1s")
+	assert.Less(t, int64(time.Since(start)), int64(3*time.Second))
+}
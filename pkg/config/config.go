@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/mitchellh/go-homedir"
 	jww "github.com/spf13/jwalterweatherman"
@@ -11,8 +13,14 @@ import (
 	"gopkg.in/ini.v1"
 )
 
-// defaultFile is the name of the default wakatime config file.
-const defaultFile = ".wakatime.cfg"
+const (
+	// defaultFile is the name of the default wakatime config file.
+	defaultFile = ".wakatime.cfg"
+	// internalFile is the name of the file storing internal state, which is not configured by users.
+	internalFile = ".wakatime-internal.cfg"
+	// offlineFile is the name of the offline queue db file.
+	offlineFile = ".wakatime.db"
+)
 
 // Writer defines the methods to write to config file.
 type Writer interface {
@@ -25,13 +33,23 @@ type IniWriter struct {
 	ConfigFilepath string
 }
 
-// NewIniWriter creates a new IniWriter instance.
+// NewIniWriter creates a new IniWriter instance. A missing config file is
+// created on first write.
 func NewIniWriter(v *viper.Viper, filepathFn func(v *viper.Viper) (string, error)) (*IniWriter, error) {
 	configFilepath, err := filepathFn(v)
 	if err != nil {
 		return nil, ErrFileParse(fmt.Sprintf("error getting filepath: %s", err))
 	}
 
+	if _, err := os.Stat(configFilepath); os.IsNotExist(err) {
+		jww.DEBUG.Printf("config file %q not found. creating it on write", configFilepath)
+
+		return &IniWriter{
+			File:           ini.Empty(),
+			ConfigFilepath: configFilepath,
+		}, nil
+	}
+
 	ini, err := ini.Load(configFilepath)
 	if err != nil {
 		return nil, ErrFileParse(fmt.Sprintf("error loading config file: %s", err))
@@ -53,6 +71,17 @@ func (w *IniWriter) Write(section string, keyValue map[string]string) error {
 		w.File.Section(section).Key(key).SetValue(value)
 	}
 
+	// the config file contains the api key, so it is created readable only by the current user
+	if _, err := os.Stat(w.ConfigFilepath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(w.ConfigFilepath), 0700); err != nil {
+			return ErrFileWrite(fmt.Sprintf("error creating config file directory: %s", err))
+		}
+
+		if err := ioutil.WriteFile(w.ConfigFilepath, nil, 0600); err != nil {
+			return ErrFileWrite(fmt.Sprintf("error creating config file: %s", err))
+		}
+	}
+
 	if err := w.File.SaveTo(w.ConfigFilepath); err != nil {
 		return ErrFileWrite(fmt.Sprintf("error saving wakatime config: %s", err))
 	}
@@ -91,6 +120,38 @@ func FilePath(v *viper.Viper) (string, error) {
 		return p, nil
 	}
 
+	home, err := WakaHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(home, defaultFile), nil
+}
+
+// InternalFilePath returns the path for the internal state file.
+func InternalFilePath() (string, error) {
+	home, err := WakaHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(home, internalFile), nil
+}
+
+// OfflineFilePath returns the path of the offline queue db file, which is
+// stored in the wakatime home directory.
+func OfflineFilePath() (string, error) {
+	home, err := WakaHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(home, offlineFile), nil
+}
+
+// WakaHomeDir returns the directory, where wakatime files are stored. Uses the
+// WAKATIME_HOME environment variable if set, otherwise the user's home directory.
+func WakaHomeDir() (string, error) {
 	home, exists := os.LookupEnv("WAKATIME_HOME")
 	if exists && home != "" {
 		p, err := homedir.Expand(home)
@@ -98,7 +159,7 @@ func FilePath(v *viper.Viper) (string, error) {
 			return "", fmt.Errorf("failed parsing WAKATIME_HOME environment variable: %s", err)
 		}
 
-		return path.Join(p, defaultFile), nil
+		return p, nil
 	}
 
 	home, err := os.UserHomeDir()
@@ -106,5 +167,5 @@ func FilePath(v *viper.Viper) (string, error) {
 		return "", fmt.Errorf("failed getting user's home directory: %s", err)
 	}
 
-	return path.Join(home, defaultFile), nil
+	return home, nil
 }
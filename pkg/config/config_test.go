@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/wakatime/wakatime-cli/pkg/config"
@@ -81,6 +83,68 @@ func TestFilePath(t *testing.T) {
 	}
 }
 
+func TestWakaHomeDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		EnvVar   string
+		Expected string
+	}{
+		"default": {
+			Expected: home,
+		},
+		"env": {
+			EnvVar:   "~/path2",
+			Expected: path.Join(home, "path2"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := os.Setenv("WAKATIME_HOME", test.EnvVar)
+			require.NoError(t, err)
+
+			defer os.Unsetenv("WAKATIME_HOME")
+
+			dir, err := config.WakaHomeDir()
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, dir)
+		})
+	}
+}
+
+func TestInternalFilePath(t *testing.T) {
+	err := os.Setenv("WAKATIME_HOME", "~/path2")
+	require.NoError(t, err)
+
+	defer os.Unsetenv("WAKATIME_HOME")
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	fp, err := config.InternalFilePath()
+	require.NoError(t, err)
+
+	assert.Equal(t, path.Join(home, "path2", ".wakatime-internal.cfg"), fp)
+}
+
+func TestOfflineFilePath(t *testing.T) {
+	err := os.Setenv("WAKATIME_HOME", "~/path2")
+	require.NoError(t, err)
+
+	defer os.Unsetenv("WAKATIME_HOME")
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	fp, err := config.OfflineFilePath()
+	require.NoError(t, err)
+
+	assert.Equal(t, path.Join(home, "path2", ".wakatime.db"), fp)
+}
+
 func TestNewIniWriter(t *testing.T) {
 	v := viper.New()
 	w, err := config.NewIniWriter(v, func(vp *viper.Viper) (string, error) {
@@ -93,6 +157,20 @@ func TestNewIniWriter(t *testing.T) {
 	assert.NotNil(t, w.File)
 }
 
+func TestNewIniWriter_MissingFile(t *testing.T) {
+	v := viper.New()
+	w, err := config.NewIniWriter(v, func(vp *viper.Viper) (string, error) {
+		return "testdata/nonexisting.cfg", nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "testdata/nonexisting.cfg", w.ConfigFilepath)
+	assert.NotNil(t, w.File)
+
+	_, err = os.Stat("testdata/nonexisting.cfg")
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestNewIniWriterErr(t *testing.T) {
 	v := viper.New()
 	_, err := config.NewIniWriter(v, func(vp *viper.Viper) (string, error) {
@@ -145,6 +223,37 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWrite_CreatesFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, "wakatime", ".wakatime.cfg")
+
+	w := config.IniWriter{
+		File:           ini.Empty(),
+		ConfigFilepath: fp,
+	}
+
+	err = w.Write("settings", map[string]string{"api_key": "00000000-0000-4000-8000-000000000000"})
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[settings]\napi_key = 00000000-0000-4000-8000-000000000000\n\n", string(data))
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	info, err := os.Stat(fp)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
 func TestWriteErr(t *testing.T) {
 	w := config.IniWriter{}
 
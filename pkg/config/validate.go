@@ -0,0 +1,497 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/api"
+	"github.com/wakatime/wakatime-cli/pkg/glob"
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
+	"gopkg.in/ini.v1"
+)
+
+// valueKind defines how the value of a known config key is validated.
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindInt
+	kindAPIKey
+	kindProxy
+	kindRegex
+	// kindRegexLines is a newline separated list of regular expressions.
+	kindRegexLines
+	// kindPatternFields is a whitespace separated list of regex or glob patterns.
+	kindPatternFields
+	// kindBoolOrPatternLines is a boolean or a newline separated list of regex or glob patterns.
+	kindBoolOrPatternLines
+	// kindSanitizeRules is a newline separated list of [sanitize] rules.
+	kindSanitizeRules
+	// kindAPIMirrors is a newline separated list of "api_key | api_url=url" entries.
+	kindAPIMirrors
+)
+
+// keySpec describes a known config key.
+type keySpec struct {
+	Kind valueKind
+	// DeprecatedBy is the name of the key replacing a deprecated alias.
+	DeprecatedBy string
+}
+
+// knownKeys contains the known keys per section. Sections with arbitrary keys
+// are listed in mapSections.
+// nolint
+var knownKeys = map[string]map[string]keySpec{
+	"settings": {
+		"api_key":                        {Kind: kindAPIKey},
+		"api_key_file":                   {Kind: kindString},
+		"api_key_pattern":                {Kind: kindRegex},
+		"api_key_vault_cmd":              {Kind: kindString},
+		"api_key_vault_timeout":          {Kind: kindInt},
+		"api_mirrors":                    {Kind: kindAPIMirrors},
+		"api_url":                        {Kind: kindString},
+		"apikey":                         {Kind: kindAPIKey, DeprecatedBy: "api_key"},
+		"debug":                          {Kind: kindBool},
+		"exclude":                        {Kind: kindPatternFields},
+		"exclude_binary_files":           {Kind: kindBool},
+		"exclude_branches":               {Kind: kindPatternFields},
+		"exclude_categories":             {Kind: kindPatternFields},
+		"exclude_git_ignored":            {Kind: kindBool},
+		"exclude_languages":              {Kind: kindPatternFields},
+		"exclude_projects":               {Kind: kindPatternFields},
+		"exclude_unknown_project":        {Kind: kindBool},
+		"file_stats_max_size":            {Kind: kindInt},
+		"hide_branch_names":              {Kind: kindBoolOrPatternLines},
+		"hide_branchnames":               {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_branch_names"},
+		"hide_domain_names":              {Kind: kindBoolOrPatternLines},
+		"hide_file_names":                {Kind: kindBoolOrPatternLines},
+		"hide_file_names_key":            {Kind: kindString},
+		"hide_file_names_mode":           {Kind: kindString},
+		"hide_filenames":                 {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_file_names"},
+		"hide_project_folder":            {Kind: kindBool},
+		"hide_project_names":             {Kind: kindBoolOrPatternLines},
+		"hide_projectnames":              {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_project_names"},
+		"hide_url_mode":                  {Kind: kindString},
+		"hide_url_params":                {Kind: kindString},
+		"hidebranchnames":                {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_branch_names"},
+		"hidefilenames":                  {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_file_names"},
+		"hideprojectnames":               {Kind: kindBoolOrPatternLines, DeprecatedBy: "hide_project_names"},
+		"hostname":                       {Kind: kindString},
+		"ignore":                         {Kind: kindPatternFields, DeprecatedBy: "exclude"},
+		"include":                        {Kind: kindPatternFields},
+		"include_branches":               {Kind: kindPatternFields},
+		"include_categories":             {Kind: kindPatternFields},
+		"include_languages":              {Kind: kindPatternFields},
+		"include_only_with_project_file": {Kind: kindBool},
+		"include_projects":               {Kind: kindPatternFields},
+		"issue_key_pattern":              {Kind: kindRegex},
+		"log_file":                       {Kind: kindString},
+		"no_ssl_verify":                  {Kind: kindBool},
+		"offline_encryption":             {Kind: kindBool},
+		"offline_key_file":               {Kind: kindString},
+		"proxy":                          {Kind: kindProxy},
+		"secret_scanning":                {Kind: kindString},
+		"secret_scanning_entropy":        {Kind: kindBool},
+		"ssl_certs_file":                 {Kind: kindString},
+		"status_bar_coding_activity":     {Kind: kindBool},
+		"status_bar_enabled":             {Kind: kindBool},
+		"strict":                         {Kind: kindBool},
+		"timeout":                        {Kind: kindInt},
+	},
+	"git": {
+		"submodules_disabled": {Kind: kindBoolOrPatternLines},
+	},
+	"project_normalization": {
+		"lowercase":      {Kind: kindBool},
+		"strip_suffixes": {Kind: kindRegexLines},
+	},
+	"sanitize": {
+		"rules": {Kind: kindSanitizeRules},
+	},
+	"schedule": {
+		"days":            {Kind: kindString},
+		"holidays_file":   {Kind: kindString},
+		"hours":           {Kind: kindString},
+		"outside_api_key": {Kind: kindAPIKey},
+		"outside_project": {Kind: kindString},
+		"timezone":        {Kind: kindString},
+	},
+}
+
+// mapSections contains the sections with arbitrary keys. If true, keys are
+// regular expressions, or glob patterns with "glob:" prefix in sections listed
+// in globSections.
+// nolint
+var mapSections = map[string]bool{
+	"api_routes":      true,
+	"branchmap":       true,
+	"project_aliases": false,
+	"project_replace": true,
+	"projectmap":      true,
+}
+
+// globSections contains the map sections accepting glob pattern keys.
+// nolint
+var globSections = map[string]bool{
+	"api_routes": true,
+	"projectmap": true,
+}
+
+// Problem is an issue found in a config file.
+type Problem struct {
+	Line    int
+	Section string
+	Key     string
+	Message string
+}
+
+// String returns a human readable description of the problem.
+func (p Problem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("line %d: [%s] %s", p.Line, p.Section, p.Message)
+	}
+
+	return fmt.Sprintf("line %d: [%s] %s: %s", p.Line, p.Section, p.Key, p.Message)
+}
+
+// Validate checks the config file at fp for unknown sections and keys,
+// deprecated aliases, invalid patterns, proxy urls and api keys. Returns the
+// found problems in order of their line number.
+func Validate(fp string) ([]Problem, error) {
+	f, err := os.Open(fp) // nolint:gosec
+	if err != nil {
+		return nil, ErrFileParse(fmt.Sprintf("error opening config file: %s", err))
+	}
+
+	defer f.Close()
+
+	keySettings := loadKeySettings(fp)
+
+	var (
+		problems []Problem
+		section  string
+		n        int
+	)
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				problems = append(problems, Problem{Line: n, Section: section, Message: "unterminated section header"})
+				continue
+			}
+
+			section = strings.ToLower(strings.TrimSpace(line[1:end]))
+
+			if _, ok := knownKeys[section]; !ok {
+				if _, ok := mapSections[section]; !ok {
+					problems = append(problems, Problem{Line: n, Section: section, Message: "unknown section"})
+				}
+			}
+
+			continue
+		}
+
+		start := n
+
+		// multi-line values are enclosed in triple quotes
+		for strings.Count(line, `"""`) == 1 && scanner.Scan() {
+			n++
+			line += "\n" + scanner.Text()
+		}
+
+		key, value, ok := splitKeyValue(line)
+		if !ok {
+			problems = append(problems, Problem{Line: start, Section: section, Message: "missing key-value delimiter"})
+			continue
+		}
+
+		// the ini parser splits unquoted keys with a "glob:" prefix at the colon,
+		// so several of them collapse into one key
+		if globSections[section] && strings.ToLower(key) == "glob" {
+			pattern := strings.TrimSpace(strings.SplitN(value, "=", 2)[0])
+
+			problems = append(problems, Problem{
+				Line:    start,
+				Section: section,
+				Key:     matcher.GlobPrefix + pattern,
+				Message: fmt.Sprintf("glob patterns must be quoted, like %q", matcher.GlobPrefix+pattern),
+			})
+
+			continue
+		}
+
+		for _, msg := range validateKey(section, key, value, keySettings) {
+			problems = append(problems, Problem{Line: start, Section: section, Key: key, Message: msg})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, ErrFileParse(fmt.Sprintf("error reading config file: %s", err))
+	}
+
+	return problems, nil
+}
+
+// splitKeyValue splits a config line into key and value the way the ini
+// parser does. Keys can be quoted. Unquoted values end at an inline comment.
+func splitKeyValue(line string) (string, string, bool) {
+	var key, rest string
+
+	if q := line[0]; q == '"' || q == '`' {
+		end := strings.IndexByte(line[1:], q)
+		if end < 0 {
+			return "", "", false
+		}
+
+		key, rest = line[1:end+1], strings.TrimSpace(line[end+2:])
+		if rest == "" || (rest[0] != '=' && rest[0] != ':') {
+			return "", "", false
+		}
+
+		rest = rest[1:]
+	} else {
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			return "", "", false
+		}
+
+		key, rest = strings.TrimSpace(line[:i]), line[i+1:]
+	}
+
+	value := strings.TrimSpace(rest)
+
+	if len(value) > 5 && strings.HasPrefix(value, `"""`) && strings.HasSuffix(value, `"""`) {
+		value = value[3 : len(value)-3]
+	} else if len(value) > 1 && (value[0] == '"' || value[0] == '`') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	} else if i := strings.IndexAny(value, "#;"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return key, value, true
+}
+
+// ValidateValue validates the value of a config key the way Validate does,
+// e.g. before writing it. Api keys are validated for the api url apiURL.
+func ValidateValue(section, key, value, apiURL string) error {
+	messages := validateKey(strings.ToLower(section), strings.ToLower(key), value, apiKeySettings{APIUrl: apiURL})
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(messages, ". "))
+}
+
+// validateKey validates a single key and its value and returns a message per problem.
+func validateKey(section, key, value string, keySettings apiKeySettings) []string {
+	if patternKeys, ok := mapSections[section]; ok {
+		if !patternKeys {
+			return nil
+		}
+
+		if err := validateMapKey(section, key); err != nil {
+			return []string{err.Error()}
+		}
+
+		return nil
+	}
+
+	if section == "" {
+		return []string{"key outside of any section"}
+	}
+
+	keys, ok := knownKeys[section]
+	if !ok {
+		// unknown section already reported
+		return nil
+	}
+
+	spec, ok := keys[strings.ToLower(key)]
+	if !ok {
+		return []string{"unknown key"}
+	}
+
+	var messages []string
+
+	if spec.DeprecatedBy != "" {
+		messages = append(messages, fmt.Sprintf("deprecated alias, use %q instead", spec.DeprecatedBy))
+	}
+
+	if err := validateValue(spec.Kind, value, keySettings); err != nil {
+		messages = append(messages, err.Error())
+	}
+
+	return messages
+}
+
+// validateMapKey validates a key of a section mapping patterns to values.
+func validateMapKey(section, key string) error {
+	if globSections[section] && strings.HasPrefix(key, matcher.GlobPrefix) {
+		if _, err := glob.ToRegex(strings.TrimPrefix(key, matcher.GlobPrefix)); err != nil {
+			return fmt.Errorf("invalid glob pattern: %s", err)
+		}
+
+		return nil
+	}
+
+	if _, err := regexp.Compile(key); err != nil {
+		return fmt.Errorf("invalid regex pattern: %s", err)
+	}
+
+	return nil
+}
+
+// validateValue validates a value by its kind.
+func validateValue(kind valueKind, value string, keySettings apiKeySettings) error {
+	switch kind {
+	case kindBool:
+		if value == "" {
+			return nil
+		}
+
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+	case kindInt:
+		if value == "" {
+			return nil
+		}
+
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+	case kindAPIKey:
+		if value != "" && api.ValidateKey(value, keySettings.Formats(keySettings.APIUrl)) != nil {
+			return fmt.Errorf("malformed api key")
+		}
+	case kindProxy:
+		if value != "" {
+			return api.ValidateProxyURL(value)
+		}
+	case kindRegex:
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid regex pattern: %s", err)
+		}
+	case kindRegexLines:
+		for _, s := range strings.Split(value, "\n") {
+			if _, err := regexp.Compile(strings.TrimSpace(s)); err != nil {
+				return fmt.Errorf("invalid regex pattern: %s", err)
+			}
+		}
+	case kindPatternFields:
+		for _, s := range strings.Fields(value) {
+			if _, err := matcher.Compile(s); err != nil {
+				return fmt.Errorf("invalid pattern: %s", err)
+			}
+		}
+	case kindBoolOrPatternLines:
+		if b := strings.ToLower(value); b == "" || b == "true" || b == "false" {
+			return nil
+		}
+
+		for _, s := range strings.Split(value, "\n") {
+			if _, err := matcher.Compile(s); err != nil {
+				return fmt.Errorf("invalid pattern: %s", err)
+			}
+		}
+	case kindSanitizeRules:
+		for _, s := range strings.Split(value, "\n") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+
+			if _, err := heartbeat.ParseSanitizeRule(s); err != nil {
+				return err
+			}
+		}
+	case kindAPIMirrors:
+		for _, s := range strings.Split(value, "\n") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+
+			if err := validateAPIMirror(s, keySettings); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAPIMirror validates a settings.api_mirrors entry of the form "api_key | api_url=url".
+func validateAPIMirror(entry string, keySettings apiKeySettings) error {
+	parts := strings.Split(entry, "|")
+
+	var apiURL string
+
+	for _, part := range parts[1:] {
+		option := strings.SplitN(part, "=", 2)
+		if len(option) == 2 && strings.TrimSpace(option[0]) == "api_url" {
+			apiURL = strings.TrimSpace(option[1])
+		}
+	}
+
+	if apiURL == "" {
+		return fmt.Errorf("missing api_url option")
+	}
+
+	if key := strings.TrimSpace(parts[0]); key != "" && api.ValidateKey(key, keySettings.Formats(apiURL)) != nil {
+		return fmt.Errorf("malformed api key")
+	}
+
+	return nil
+}
+
+// apiKeySettings contains the settings needed to validate api keys.
+type apiKeySettings struct {
+	APIUrl string
+	Extra  []api.KeyFormat
+}
+
+// Formats returns the api key formats accepted for apiURL.
+func (s apiKeySettings) Formats(apiURL string) []api.KeyFormat {
+	return api.KeyFormats(apiURL, s.Extra...)
+}
+
+// loadKeySettings reads api_url and api_key_pattern from the config file at fp,
+// as api keys may be defined before them. Invalid settings are ignored here,
+// as they are reported as problems of their own.
+func loadKeySettings(fp string) apiKeySettings {
+	f, err := ini.Load(fp)
+	if err != nil {
+		return apiKeySettings{}
+	}
+
+	settings := f.Section("settings")
+
+	keys := apiKeySettings{
+		APIUrl: settings.Key("api_url").String(),
+	}
+
+	if pattern := settings.Key("api_key_pattern").String(); pattern != "" {
+		if format, err := api.NewKeyFormat("custom", pattern); err == nil {
+			keys.Extra = []api.KeyFormat{format}
+		}
+	}
+
+	return keys
+}
@@ -11,6 +11,8 @@ const (
 	ErrAuth = 104
 	// ErrConfigFileParse is used when the ~/.wakatime.cfg config file could not be parsed.
 	ErrConfigFileParse = 103
+	// ErrConnection is used when the api could not be reached, e.g. because of network errors.
+	ErrConnection = 107
 	// ErrConfigFileRead is used for errors of config read command.
 	ErrConfigFileRead = 110
 	// ErrConfigFileWrite is used for errors of config write command.
@@ -11,14 +11,27 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 )
 
-// Max file size supporting line number count stats. Files larger than this in
-// bytes will not have a line count stat for performance. Default is 2MB (2*1024*1014).
-const maxFileSizeSupported = 2097152
+// DefaultMaxFileSize is the default max file size supporting line number count
+// stats. Files larger than this in bytes will not have a line count stat for
+// performance. Default is 2MB (2*1024*1024).
+const DefaultMaxFileSize = 2097152
+
+// Config contains file stats detection configurations.
+type Config struct {
+	// MaxFileSize is the max file size in bytes supporting line number count
+	// stats. Defaults to DefaultMaxFileSize, if not positive.
+	MaxFileSize int64
+}
 
 // WithDetection initializes and returns a heartbeat handle option, which
 // can be used in a heartbeat processing pipeline to detect filestats. At the
 // moment only the total number of lines in a file is detected.
-func WithDetection() heartbeat.HandleOption {
+func WithDetection(c Config) heartbeat.HandleOption {
+	maxFileSize := c.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+
 	return func(next heartbeat.Handle) heartbeat.Handle {
 		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 			for n, h := range hh {
@@ -29,11 +42,11 @@ func WithDetection() heartbeat.HandleOption {
 						continue
 					}
 
-					if fileInfo.Size() > maxFileSizeSupported {
+					if fileInfo.Size() > maxFileSize {
 						jww.DEBUG.Printf(
 							"file %q exceeds max file size of %d bytes. Lines won't be counted",
 							h.Entity,
-							maxFileSizeSupported,
+							maxFileSize,
 						)
 
 						continue
@@ -14,7 +14,7 @@ import (
 )
 
 func TestWithDetection(t *testing.T) {
-	opt := filestats.WithDetection()
+	opt := filestats.WithDetection(filestats.Config{})
 	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 		assert.Len(t, hh, 2)
 		assert.Contains(t, hh, heartbeat.Heartbeat{
@@ -64,7 +64,7 @@ func TestWithDetection_MaxFileSizeExceeded(t *testing.T) {
 	_, err = f.Write(b.Bytes())
 	require.NoError(t, err)
 
-	opt := filestats.WithDetection()
+	opt := filestats.WithDetection(filestats.Config{})
 	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 		assert.Equal(t, hh, []heartbeat.Heartbeat{
 			{
@@ -85,3 +85,45 @@ func TestWithDetection_MaxFileSizeExceeded(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestWithDetection_CustomMaxFileSize(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "")
+	require.NoError(t, err)
+
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("first\nsecond\nthird\n")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		MaxFileSize int64
+		Expected    *int
+	}{
+		"below limit": {
+			MaxFileSize: 100,
+			Expected:    heartbeat.Int(3),
+		},
+		"exceeds limit": {
+			MaxFileSize: 10,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opt := filestats.WithDetection(filestats.Config{MaxFileSize: test.MaxFileSize})
+			handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+				assert.Equal(t, test.Expected, hh[0].Lines)
+
+				return []heartbeat.Result{}, nil
+			})
+
+			_, err = handle([]heartbeat.Heartbeat{
+				{
+					EntityType: heartbeat.FileType,
+					Entity:     f.Name(),
+				},
+			})
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// binarySniffSize is the number of bytes at the start of a file, which are
+// checked for NUL bytes to detect binary content. Same as git uses.
+const binarySniffSize = 8000
+
+// binaryExtensions contains extensions of files, which are known to be binary.
+// nolint
+var binaryExtensions = map[string]struct{}{
+	// images
+	".bmp": {}, ".gif": {}, ".ico": {}, ".jpeg": {}, ".jpg": {}, ".png": {}, ".psd": {}, ".tif": {},
+	".tiff": {}, ".webp": {},
+	// audio and video
+	".avi": {}, ".flac": {}, ".mkv": {}, ".mov": {}, ".mp3": {}, ".mp4": {}, ".ogg": {}, ".wav": {},
+	".webm": {},
+	// archives
+	".7z": {}, ".bz2": {}, ".gz": {}, ".jar": {}, ".rar": {}, ".tar": {}, ".tgz": {}, ".war": {},
+	".xz": {}, ".zip": {}, ".zst": {},
+	// compiled objects and executables
+	".a": {}, ".class": {}, ".dll": {}, ".dylib": {}, ".exe": {}, ".lib": {}, ".o": {}, ".obj": {},
+	".pyc": {}, ".pyo": {}, ".so": {}, ".wasm": {},
+	// documents and fonts
+	".doc": {}, ".docx": {}, ".eot": {}, ".otf": {}, ".pdf": {}, ".ppt": {}, ".pptx": {}, ".ttf": {},
+	".woff": {}, ".woff2": {}, ".xls": {}, ".xlsx": {},
+	// databases
+	".db": {}, ".sqlite": {}, ".sqlite3": {},
+}
+
+// IsBinary checks if the file at fp is binary. A file is considered binary,
+// if it has a known binary extension or contains a NUL byte within its first
+// 8000 bytes.
+func IsBinary(fp string) (bool, error) {
+	if _, ok := binaryExtensions[strings.ToLower(filepath.Ext(fp))]; ok {
+		return true, nil
+	}
+
+	f, err := os.Open(fp) // nolint:gosec
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %s", err)
+	}
+
+	defer f.Close()
+
+	buf := make([]byte, binarySniffSize)
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("failed to read file: %s", err)
+	}
+
+	return bytes.IndexByte(buf[:n], 0) != -1, nil
+}
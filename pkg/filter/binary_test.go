@@ -0,0 +1,112 @@
+package filter_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/filter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBinary(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	tests := map[string]struct {
+		Filename string
+		Content  []byte
+		Expected bool
+	}{
+		"source file": {
+			Filename: "main.go",
+			Content:  []byte("package main\n"),
+		},
+		"empty file": {
+			Filename: "empty.txt",
+		},
+		"utf-8 text": {
+			Filename: "README",
+			Content:  []byte("Grüße 👋\n"),
+		},
+		"nul byte": {
+			Filename: "data",
+			Content:  []byte{0x7f, 'E', 'L', 'F', 0x02, 0x01, 0x01, 0x00},
+			Expected: true,
+		},
+		"nul byte after sniffed block": {
+			Filename: "large.txt",
+			Content:  append(bytes.Repeat([]byte("a"), 8000), 0),
+		},
+		"known extension": {
+			Filename: "logo.PNG",
+			Content:  []byte("not actually an image"),
+			Expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fp := filepath.Join(tmpDir, test.Filename)
+
+			err := ioutil.WriteFile(fp, test.Content, 0600)
+			require.NoError(t, err)
+
+			binary, err := filter.IsBinary(fp)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, binary)
+		})
+	}
+}
+
+func TestFilter_ErrBinaryFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, "main.o")
+
+	err = ioutil.WriteFile(fp, []byte{0x00, 0x01}, 0600)
+	require.NoError(t, err)
+
+	h := testHeartbeat()
+	h.Entity = fp
+
+	err = filter.Filter(h, filter.Config{})
+	require.NoError(t, err)
+
+	err = filter.Filter(h, filter.Config{ExcludeBinaryFiles: true})
+
+	var errv filter.Err
+
+	assert.True(t, errors.As(err, &errv))
+	assert.Contains(t, err.Error(), "skipping because of binary file")
+}
+
+func TestFilter_BinaryFile_Unreadable(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	// a directory cannot be read as file
+	fp := filepath.Join(tmpDir, "main")
+
+	err = os.Mkdir(fp, 0700)
+	require.NoError(t, err)
+
+	h := testHeartbeat()
+	h.Entity = fp
+
+	err = filter.Filter(h, filter.Config{ExcludeBinaryFiles: true})
+	require.NoError(t, err)
+}
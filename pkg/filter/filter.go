@@ -4,42 +4,67 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/ignore"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 	"github.com/wakatime/wakatime-cli/pkg/project"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/yookoala/realpath"
 )
 
+// ignoreFile is the name of files containing gitignore style patterns of
+// files, which should not be tracked.
+const ignoreFile = ".wakatimeignore"
+
 // Config contains filtering configurations.
 type Config struct {
-	Exclude                    []*regexp.Regexp
+	Exclude                    []matcher.Matcher
+	ExcludeBinaryFiles         bool
+	ExcludeBranches            []matcher.Matcher
+	ExcludeCategories          []matcher.Matcher
+	ExcludeGitIgnored          bool
+	ExcludeLanguages           []matcher.Matcher
+	ExcludeProjects            []matcher.Matcher
 	ExcludeUnknownProject      bool
-	Include                    []*regexp.Regexp
+	Include                    []matcher.Matcher
+	IncludeBranches            []matcher.Matcher
+	IncludeCategories          []matcher.Matcher
+	IncludeLanguages           []matcher.Matcher
 	IncludeOnlyWithProjectFile bool
+	IncludeProjects            []matcher.Matcher
+	// Trace optionally records why heartbeats were skipped.
+	Trace *heartbeat.Trace
 }
 
 // WithFiltering initializes and returns a heartbeat handle option, which
 // can be used in a heartbeat processing pipeline to filter heartbeats following
-// the provided configurations.
+// the provided configurations. Git ignore rules are cached for the life of
+// the handle option.
 func WithFiltering(config Config) heartbeat.HandleOption {
+	gitIgnore := ignore.NewGitCache()
+
 	return func(next heartbeat.Handle) heartbeat.Handle {
 		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 			var filtered []heartbeat.Heartbeat
 
 			for _, h := range hh {
-				err := Filter(h, config)
+				err := filter(h, config, gitIgnore)
 				if err != nil {
 					var errv Err
 					if errors.As(err, &errv) {
 						jww.DEBUG.Println(errv.Error())
+						config.Trace.Add("filter", "%s: %s", h.Entity, errv)
+
 						continue
 					}
 
 					return nil, fmt.Errorf("error filtering heartbeat: %w", err)
 				}
 
+				config.Trace.Add("filter", "%s: passed all filters", h.Entity)
+
 				filtered = append(filtered, h)
 			}
 
@@ -57,22 +82,95 @@ func WithFiltering(config Config) heartbeat.HandleOption {
 // should be skipped.
 // Returns Err to signal to the caller to skip the heartbeat.
 func Filter(h heartbeat.Heartbeat, config Config) error {
+	return filter(h, config, ignore.NewGitCache())
+}
+
+// filter is Filter with a git ignore cache, which can be shared across heartbeats.
+func filter(h heartbeat.Heartbeat, config Config, gitIgnore *ignore.GitCache) error {
 	// unknown project
 	if config.ExcludeUnknownProject && (h.Project == nil || *h.Project == "") {
 		return Err("skipping because of unknown project")
 	}
 
 	// filter by pattern
-	if err := filterByPattern(h.Entity, config.Include, config.Exclude); err != nil {
+	included, err := filterByPattern(h.Entity, config.Include, config.Exclude)
+	if err != nil {
 		return fmt.Errorf("filter by pattern: %w", err)
 	}
 
+	if included != nil {
+		config.Trace.Add("filter", "%s: matches include pattern %q", h.Entity, included.String())
+	}
+
+	// filter by project, branch, language and category patterns
+	fields := []struct {
+		Name    string
+		Value   string
+		Include []matcher.Matcher
+		Exclude []matcher.Matcher
+	}{
+		{
+			Name:    "project",
+			Value:   stringValue(h.Project),
+			Include: config.IncludeProjects,
+			Exclude: config.ExcludeProjects,
+		},
+		{
+			Name:    "branch",
+			Value:   stringValue(h.Branch),
+			Include: config.IncludeBranches,
+			Exclude: config.ExcludeBranches,
+		},
+		{
+			Name:    "language",
+			Value:   stringValue(h.Language),
+			Include: config.IncludeLanguages,
+			Exclude: config.ExcludeLanguages,
+		},
+		{
+			Name:    "category",
+			Value:   h.Category.String(),
+			Include: config.IncludeCategories,
+			Exclude: config.ExcludeCategories,
+		},
+	}
+
+	for _, field := range fields {
+		included, err := filterByPattern(field.Value, field.Include, field.Exclude)
+		if err != nil {
+			return fmt.Errorf("filter by %s pattern: %w", field.Name, err)
+		}
+
+		if included != nil {
+			config.Trace.Add("filter", "%s: %s matches include pattern %q", h.Entity, field.Name, included.String())
+		}
+	}
+
 	// filter file
 	if h.EntityType == heartbeat.FileType {
 		err := filterFileEntity(h.Entity, config.IncludeOnlyWithProjectFile)
 		if err != nil {
 			return fmt.Errorf("filter file: %w", err)
 		}
+
+		if config.ExcludeBinaryFiles {
+			if err := filterBinaryFile(h.Entity); err != nil {
+				return fmt.Errorf("filter binary file: %w", err)
+			}
+		}
+
+		// include patterns from user config override .wakatimeignore and .gitignore files
+		if included == nil {
+			if err := filterByIgnoreFiles(h.Entity, h.ProjectPath); err != nil {
+				return fmt.Errorf("filter by ignore files: %w", err)
+			}
+
+			if config.ExcludeGitIgnored {
+				if err := filterGitIgnored(h.Entity, gitIgnore); err != nil {
+					return fmt.Errorf("filter git ignored: %w", err)
+				}
+			}
+		}
 	}
 
 	return nil
@@ -80,26 +178,105 @@ func Filter(h heartbeat.Heartbeat, config Config) error {
 
 // filterByPattern determines if a heartbeat should be skipped by checking an
 // entity against include and exclude patterns. Include will override exclude.
+// Returns the matching include pattern, if any.
 // Returns Err to signal to the caller to skip the heartbeat.
-func filterByPattern(entity string, include, exclude []*regexp.Regexp) error {
+func filterByPattern(entity string, include, exclude []matcher.Matcher) (matcher.Matcher, error) {
 	if entity == "" {
-		return nil
+		return nil, nil
 	}
 
 	// filter by include pattern
 	for _, pattern := range include {
 		if pattern.MatchString(entity) {
-			return nil
+			return pattern, nil
 		}
 	}
 
 	// filter by  exclude pattern
 	for _, pattern := range exclude {
 		if pattern.MatchString(entity) {
-			return Err(fmt.Sprintf("skipping because matches exclude pattern %q", pattern.String()))
+			return nil, Err(fmt.Sprintf("skipping because matches exclude pattern %q", pattern.String()))
 		}
 	}
 
+	return nil, nil
+}
+
+// stringValue returns the value of s or an empty string, if s is nil.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+// filterByIgnoreFiles determines if a heartbeat should be skipped by evaluating
+// .wakatimeignore files in all directories from the project root down to the
+// entity's directory with gitignore semantics. Files in deeper directories
+// take precedence. Nothing is filtered, if the project root is unknown or
+// the ignore files cannot be read.
+// Returns Err to signal to the caller to skip the heartbeat.
+func filterByIgnoreFiles(entity, projectPath string) error {
+	if projectPath == "" {
+		return nil
+	}
+
+	fp, err := realpath.Realpath(entity)
+	if err != nil {
+		fp = entity
+	}
+
+	m, err := ignore.LoadHierarchy(ignoreFile, fp, projectPath)
+	if err != nil {
+		jww.WARN.Printf("failed to load %s files for %q: %s", ignoreFile, fp, err)
+		return nil
+	}
+
+	if p := m.Match(fp, false); p != nil && !p.Negate {
+		return Err(fmt.Sprintf("skipping because matches ignore pattern %q", p.String()))
+	}
+
+	return nil
+}
+
+// filterGitIgnored determines if a heartbeat should be skipped, because the
+// entity is ignored by the git repository it is located in. Like git, ignore
+// files which cannot be read are skipped.
+// Returns Err to signal to the caller to skip the heartbeat.
+func filterGitIgnored(entity string, gitIgnore *ignore.GitCache) error {
+	fp, err := realpath.Realpath(entity)
+	if err != nil {
+		fp = entity
+	}
+
+	p, err := gitIgnore.Match(fp, false)
+	if err != nil {
+		jww.WARN.Printf("failed to evaluate git ignore rules for %q: %s", fp, err)
+		return nil
+	}
+
+	if p != nil && !p.Negate {
+		return Err(fmt.Sprintf("skipping because ignored by git pattern %q", p.String()))
+	}
+
+	return nil
+}
+
+// filterBinaryFile determines if a heartbeat should be skipped, because the
+// entity is a binary file. Files which cannot be read are not skipped.
+// Returns Err to signal to the caller to skip the heartbeat.
+func filterBinaryFile(entity string) error {
+	binary, err := IsBinary(entity)
+	if err != nil {
+		jww.WARN.Printf("failed to detect binary file %q: %s", entity, err)
+		return nil
+	}
+
+	if binary {
+		return Err(fmt.Sprintf("skipping because of binary file %q", entity))
+	}
+
 	return nil
 }
 
@@ -11,9 +11,11 @@ import (
 
 	"github.com/wakatime/wakatime-cli/pkg/filter"
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yookoala/realpath"
 )
 
 func TestWithFiltering(t *testing.T) {
@@ -76,6 +78,91 @@ func TestWithFiltering_AbortAllFiltered(t *testing.T) {
 	assert.Equal(t, result, []heartbeat.Result{})
 }
 
+func TestWithFiltering_Trace(t *testing.T) {
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "")
+	require.NoError(t, err)
+
+	defer os.Remove(tmpFile.Name())
+
+	included := testHeartbeat()
+	included.Entity = tmpFile.Name()
+
+	trace := &heartbeat.Trace{}
+
+	opt := filter.WithFiltering(filter.Config{
+		ExcludeProjects: []matcher.Matcher{regexp.MustCompile("^waka")},
+		Include:         []matcher.Matcher{regexp.MustCompile(regexp.QuoteMeta(tmpFile.Name()))},
+		IncludeProjects: []matcher.Matcher{regexp.MustCompile("time$")},
+		Trace:           trace,
+	})
+	h := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		return []heartbeat.Result{}, nil
+	})
+
+	excluded := testHeartbeat()
+	excluded.Project = heartbeat.String("wakatime-cli")
+
+	_, err = h([]heartbeat.Heartbeat{included, excluded})
+	require.NoError(t, err)
+
+	assert.Equal(t, []heartbeat.TraceEntry{
+		{
+			Stage:   "filter",
+			Message: fmt.Sprintf("%s: matches include pattern %q", tmpFile.Name(), regexp.QuoteMeta(tmpFile.Name())),
+		},
+		{
+			Stage:   "filter",
+			Message: fmt.Sprintf(`%s: project matches include pattern "time$"`, tmpFile.Name()),
+		},
+		{
+			Stage:   "filter",
+			Message: fmt.Sprintf("%s: passed all filters", tmpFile.Name()),
+		},
+		{
+			Stage:   "filter",
+			Message: `/tmp/main.go: skipping because matches exclude pattern "^waka"`,
+		},
+	}, trace.Entries)
+}
+
+func TestWithFiltering_GitIgnoreCached(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(root, ".git"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, path.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, path.Join(root, "debug.log"), "")
+
+	var calls int
+
+	opt := filter.WithFiltering(filter.Config{ExcludeGitIgnored: true})
+	h := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		calls++
+		return []heartbeat.Result{}, nil
+	})
+
+	hb := testHeartbeat()
+	hb.Entity = path.Join(root, "debug.log")
+
+	_, err = h([]heartbeat.Heartbeat{hb})
+	require.NoError(t, err)
+
+	// rules read by the first call are reused by later calls
+	writeFile(t, path.Join(root, ".gitignore"), "")
+
+	_, err = h([]heartbeat.Heartbeat{hb})
+	require.NoError(t, err)
+
+	assert.Zero(t, calls)
+}
+
 func TestFilter(t *testing.T) {
 	tmpFile, err := ioutil.TempFile(os.TempDir(), "")
 	require.NoError(t, err)
@@ -110,10 +197,10 @@ func TestFilter_IncludeMatchOverwritesExcludeMatch(t *testing.T) {
 	h.Entity = tmpFile.Name()
 
 	err = filter.Filter(h, filter.Config{
-		Exclude: []*regexp.Regexp{
+		Exclude: []matcher.Matcher{
 			regexp.MustCompile(".*main.go$"),
 		},
-		Include: []*regexp.Regexp{
+		Include: []matcher.Matcher{
 			regexp.MustCompile(".*/tmp/.*"),
 		},
 	})
@@ -130,7 +217,7 @@ func TestFilter_ErrMatchesExcludePattern(t *testing.T) {
 	h.Entity = tmpFile.Name()
 
 	err = filter.Filter(h, filter.Config{
-		Exclude: []*regexp.Regexp{
+		Exclude: []matcher.Matcher{
 			regexp.MustCompile("^" + tmpFile.Name() + "$"),
 		},
 	})
@@ -219,6 +306,320 @@ func TestFilter_ErrNonExistingProjectFile(t *testing.T) {
 	assert.Equal(t, filter.Err("skipping because of missing .wakatime-project file in parent path"), errv)
 }
 
+func TestFilter_IgnoreFiles(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(root, "src", "gen"), 0700)
+	require.NoError(t, err)
+
+	// invalid patterns are skipped like in git
+	writeFile(t, path.Join(root, ".wakatimeignore"), "*.gen.go\nsrc/[gen\n/docs/\\\n")
+	writeFile(t, path.Join(root, "src", "gen", ".wakatimeignore"), "!keep.gen.go\n")
+
+	for _, fp := range []string{"src/api.gen.go", "src/main.go", "src/gen/keep.gen.go", "src/gen/other.gen.go"} {
+		writeFile(t, path.Join(root, fp), "")
+	}
+
+	tests := map[string]struct {
+		Entity      string
+		ProjectPath string
+		Config      filter.Config
+		Skipped     bool
+	}{
+		"ignored": {
+			Entity:      "src/api.gen.go",
+			ProjectPath: root,
+			Skipped:     true,
+		},
+		"not ignored": {
+			Entity:      "src/main.go",
+			ProjectPath: root,
+		},
+		"negated in deeper file": {
+			Entity:      "src/gen/keep.gen.go",
+			ProjectPath: root,
+		},
+		"parent pattern applies to deeper directory": {
+			Entity:      "src/gen/other.gen.go",
+			ProjectPath: root,
+			Skipped:     true,
+		},
+		"unknown project root": {
+			Entity: "src/api.gen.go",
+		},
+		"include overrides ignore file": {
+			Entity:      "src/api.gen.go",
+			ProjectPath: root,
+			Config: filter.Config{
+				Include: []matcher.Matcher{regexp.MustCompile(`api\.gen\.go$`)},
+			},
+		},
+		"exclude overrides negation": {
+			Entity:      "src/gen/keep.gen.go",
+			ProjectPath: root,
+			Config: filter.Config{
+				Exclude: []matcher.Matcher{regexp.MustCompile(`keep\.gen\.go$`)},
+			},
+			Skipped: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHeartbeat()
+			h.Entity = path.Join(root, test.Entity)
+			h.ProjectPath = test.ProjectPath
+
+			err := filter.Filter(h, test.Config)
+
+			if !test.Skipped {
+				require.NoError(t, err)
+				return
+			}
+
+			var errv filter.Err
+
+			assert.True(t, errors.As(err, &errv))
+		})
+	}
+}
+
+func TestFilter_IgnoreFiles_ErrMessage(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	writeFile(t, path.Join(root, ".wakatimeignore"), "*.log\n")
+	writeFile(t, path.Join(root, "debug.log"), "")
+
+	h := testHeartbeat()
+	h.Entity = path.Join(root, "debug.log")
+	h.ProjectPath = root
+
+	err = filter.Filter(h, filter.Config{})
+
+	var errv filter.Err
+
+	assert.True(t, errors.As(err, &errv))
+	assert.Equal(t, filter.Err(fmt.Sprintf(
+		"skipping because matches ignore pattern \"%s:1: *.log\"",
+		path.Join(root, ".wakatimeignore"),
+	)), errv)
+}
+
+func TestFilter_IgnoreFiles_UnreadableFile(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	// a directory cannot be read as ignore file
+	err = os.MkdirAll(path.Join(root, ".wakatimeignore"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, path.Join(root, "main.go"), "")
+
+	h := testHeartbeat()
+	h.Entity = path.Join(root, "main.go")
+	h.ProjectPath = root
+
+	err = filter.Filter(h, filter.Config{})
+	require.NoError(t, err)
+}
+
+func TestFilter_ExcludeGitIgnored(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(root, ".git"), 0700)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(root, "vendor"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, path.Join(root, ".gitignore"), "vendor/\n[unterminated\n")
+	writeFile(t, path.Join(root, "vendor", "lib.go"), "")
+	writeFile(t, path.Join(root, "main.go"), "")
+
+	tests := map[string]struct {
+		Entity  string
+		Config  filter.Config
+		Skipped bool
+	}{
+		"ignored": {
+			Entity:  "vendor/lib.go",
+			Config:  filter.Config{ExcludeGitIgnored: true},
+			Skipped: true,
+		},
+		"not ignored": {
+			Entity: "main.go",
+			Config: filter.Config{ExcludeGitIgnored: true},
+		},
+		"disabled": {
+			Entity: "vendor/lib.go",
+		},
+		"include overrides git ignore": {
+			Entity: "vendor/lib.go",
+			Config: filter.Config{
+				ExcludeGitIgnored: true,
+				Include:           []matcher.Matcher{regexp.MustCompile("vendor")},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHeartbeat()
+			h.Entity = path.Join(root, test.Entity)
+
+			err := filter.Filter(h, test.Config)
+
+			if !test.Skipped {
+				require.NoError(t, err)
+				return
+			}
+
+			var errv filter.Err
+
+			assert.True(t, errors.As(err, &errv))
+			assert.Contains(t, errv.Error(), "skipping because ignored by git pattern")
+		})
+	}
+}
+
+func TestFilter_ExcludeGitIgnored_UnreadableFile(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	root, err = realpath.Realpath(root)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(root, ".git"), 0700)
+	require.NoError(t, err)
+
+	// a directory cannot be read as ignore file
+	err = os.MkdirAll(path.Join(root, ".gitignore"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, path.Join(root, "main.go"), "")
+
+	h := testHeartbeat()
+	h.Entity = path.Join(root, "main.go")
+
+	err = filter.Filter(h, filter.Config{ExcludeGitIgnored: true})
+	require.NoError(t, err)
+}
+
+func TestFilter_FieldPatterns(t *testing.T) {
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "")
+	require.NoError(t, err)
+
+	defer os.Remove(tmpFile.Name())
+
+	tests := map[string]struct {
+		Config  filter.Config
+		Skipped bool
+	}{
+		"exclude project": {
+			Config:  filter.Config{ExcludeProjects: []matcher.Matcher{regexp.MustCompile("^waka")}},
+			Skipped: true,
+		},
+		"include project overrides exclude project": {
+			Config: filter.Config{
+				ExcludeProjects: []matcher.Matcher{regexp.MustCompile(".*")},
+				IncludeProjects: []matcher.Matcher{regexp.MustCompile("^wakatime$")},
+			},
+		},
+		"exclude branch": {
+			Config:  filter.Config{ExcludeBranches: []matcher.Matcher{regexp.MustCompile("^heart")}},
+			Skipped: true,
+		},
+		"exclude branch no match": {
+			Config: filter.Config{ExcludeBranches: []matcher.Matcher{regexp.MustCompile("^personal/")}},
+		},
+		"exclude language": {
+			Config:  filter.Config{ExcludeLanguages: []matcher.Matcher{regexp.MustCompile("(?i)^golang$")}},
+			Skipped: true,
+		},
+		"exclude category": {
+			Config:  filter.Config{ExcludeCategories: []matcher.Matcher{regexp.MustCompile("^coding$")}},
+			Skipped: true,
+		},
+		"include category overrides exclude category": {
+			Config: filter.Config{
+				ExcludeCategories: []matcher.Matcher{regexp.MustCompile(".*")},
+				IncludeCategories: []matcher.Matcher{regexp.MustCompile("^coding$")},
+			},
+		},
+		"include on other field does not override": {
+			Config: filter.Config{
+				ExcludeBranches: []matcher.Matcher{regexp.MustCompile(".*")},
+				IncludeProjects: []matcher.Matcher{regexp.MustCompile(".*")},
+			},
+			Skipped: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHeartbeat()
+			h.Entity = tmpFile.Name()
+
+			err := filter.Filter(h, test.Config)
+
+			if !test.Skipped {
+				require.NoError(t, err)
+				return
+			}
+
+			var errv filter.Err
+
+			assert.True(t, errors.As(err, &errv))
+		})
+	}
+}
+
+func TestFilter_FieldPatterns_UnsetField(t *testing.T) {
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "")
+	require.NoError(t, err)
+
+	defer os.Remove(tmpFile.Name())
+
+	h := testHeartbeat()
+	h.Entity = tmpFile.Name()
+	h.Language = nil
+
+	err = filter.Filter(h, filter.Config{
+		ExcludeLanguages: []matcher.Matcher{regexp.MustCompile(".*")},
+	})
+	require.NoError(t, err)
+}
+
+func writeFile(t *testing.T, fp, data string) {
+	err := ioutil.WriteFile(fp, []byte(data), 0600)
+	require.NoError(t, err)
+}
+
 func testHeartbeat() heartbeat.Heartbeat {
 	return heartbeat.Heartbeat{
 		Branch:         heartbeat.String("heartbeat"),
@@ -0,0 +1,314 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// dateLayout is the layout of dates in holiday files.
+const dateLayout = "2006-01-02"
+
+// ScheduleConfig defines the hours, during which activity is tracked.
+type ScheduleConfig struct {
+	// Days are the weekdays activity is tracked on. All days, if empty.
+	Days []time.Weekday
+	// Windows are the times of day activity is tracked at. The whole day, if empty.
+	Windows []TimeWindow
+	// Location is the time zone the schedule is defined in. Defaults to local time.
+	Location *time.Location
+	// Holidays contains dates in the format 2006-01-02, on which no activity is tracked.
+	Holidays map[string]struct{}
+	// OutsideAPIKey is the api key to send heartbeats outside of the schedule with.
+	// If neither OutsideAPIKey nor OutsideProject are set, these heartbeats are dropped.
+	OutsideAPIKey string
+	// OutsideProject is the project to assign to heartbeats outside of the schedule.
+	OutsideProject string
+	// Trace optionally records which heartbeats were dropped or re-tagged.
+	Trace *heartbeat.Trace
+}
+
+// TimeWindow is a time of day range. End is exclusive. If End is before Start,
+// the window spans midnight and belongs to the day it starts on.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// WithSchedule initializes and returns a heartbeat handle option, which can be
+// used in a heartbeat processing pipeline to drop or re-tag heartbeats outside
+// of the schedule.
+func WithSchedule(config ScheduleConfig) heartbeat.HandleOption {
+	return func(next heartbeat.Handle) heartbeat.Handle {
+		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			var filtered []heartbeat.Heartbeat
+
+			for _, h := range hh {
+				if config.Within(timeOf(h)) {
+					filtered = append(filtered, h)
+					continue
+				}
+
+				if config.OutsideAPIKey == "" && config.OutsideProject == "" {
+					jww.DEBUG.Printf("skipping because heartbeat at %f is outside of schedule", h.Time)
+					config.Trace.Add("schedule", "%s: skipping because outside of schedule", h.Entity)
+
+					continue
+				}
+
+				jww.DEBUG.Printf("re-tagging heartbeat at %f, because it is outside of schedule", h.Time)
+				config.Trace.Add("schedule", "%s: re-tagging because outside of schedule", h.Entity)
+
+				if config.OutsideAPIKey != "" {
+					h.APIKey = config.OutsideAPIKey
+				}
+
+				if config.OutsideProject != "" {
+					h.Project = heartbeat.String(config.OutsideProject)
+				}
+
+				filtered = append(filtered, h)
+			}
+
+			if len(filtered) == 0 {
+				jww.DEBUG.Println("no heartbeat left after schedule filtering. abort heartbeat handling.")
+				return []heartbeat.Result{}, nil
+			}
+
+			return next(filtered)
+		}
+	}
+}
+
+// Within checks if t is within the schedule. Weekdays and holidays are checked
+// against the day a time window starts on, so that windows spanning midnight
+// are attributed to a single day.
+func (c ScheduleConfig) Within(t time.Time) bool {
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+
+	if len(c.Windows) == 0 {
+		return c.isWorkday(t)
+	}
+
+	tod := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	for _, w := range c.Windows {
+		switch {
+		case w.Start <= w.End:
+			if tod >= w.Start && tod < w.End && c.isWorkday(t) {
+				return true
+			}
+		case tod >= w.Start:
+			if c.isWorkday(t) {
+				return true
+			}
+		case tod < w.End:
+			if c.isWorkday(time.Date(t.Year(), t.Month(), t.Day()-1, 12, 0, 0, 0, loc)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isWorkday checks if the date of t is a scheduled weekday and not a holiday.
+func (c ScheduleConfig) isWorkday(t time.Time) bool {
+	if _, ok := c.Holidays[t.Format(dateLayout)]; ok {
+		return false
+	}
+
+	if len(c.Days) == 0 {
+		return true
+	}
+
+	for _, d := range c.Days {
+		if d == t.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// timeOf converts the unix epoch timestamp of a heartbeat to time.Time.
+func timeOf(h heartbeat.Heartbeat) time.Time {
+	secs, frac := math.Modf(h.Time)
+	return time.Unix(int64(secs), int64(frac*1e9))
+}
+
+// nolint
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWeekdays parses a comma separated list of weekdays and weekday ranges,
+// like "mon-fri, sun". Ranges can wrap around the end of the week, like "fri-mon".
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+
+		start, err := parseWeekday(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end := start
+
+		if len(bounds) == 2 {
+			end, err = parseWeekday(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for d := start; ; d = (d + 1) % 7 {
+			days = append(days, d)
+
+			if d == end {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// parseWeekday parses a weekday by its name or three letter abbreviation.
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 3 {
+		if d, ok := weekdays[s[:3]]; ok && strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+// ParseTimeWindows parses a comma separated list of time windows in 24 hour
+// format, like "09:00-12:00, 13:00-17:30". "24:00" can be used as end of day.
+func ParseTimeWindows(s string) ([]TimeWindow, error) {
+	var windows []TimeWindow
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid time window %q", part)
+		}
+
+		start, err := parseTimeOfDay(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseTimeOfDay(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if start == end {
+			return nil, fmt.Errorf("invalid empty time window %q", part)
+		}
+
+		windows = append(windows, TimeWindow{Start: start, End: end})
+	}
+
+	return windows, nil
+}
+
+// parseTimeOfDay parses a time of day in the format 15:04.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// LoadHolidays reads holiday dates in the format 2006-01-02 from a file. Each
+// line contains one date, optionally followed by a description. Lines starting
+// with "#" are ignored.
+func LoadHolidays(fp string) (map[string]struct{}, error) {
+	f, err := os.Open(fp) // nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open holidays file: %s", err)
+	}
+
+	defer f.Close()
+
+	holidays := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(f)
+
+	var n int
+
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		date := strings.Fields(line)[0]
+
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, fmt.Errorf("invalid date %q in holidays file at line %d", date, n)
+		}
+
+		holidays[date] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read holidays file: %s", err)
+	}
+
+	return holidays, nil
+}
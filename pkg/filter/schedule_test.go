@@ -0,0 +1,263 @@
+package filter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/filter"
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleConfig_Within(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	officeHours := []filter.TimeWindow{{Start: 9 * time.Hour, End: 17 * time.Hour}}
+	nightShift := []filter.TimeWindow{{Start: 22 * time.Hour, End: 2 * time.Hour}}
+
+	tests := map[string]struct {
+		Config   filter.ScheduleConfig
+		Time     time.Time
+		Expected bool
+	}{
+		"within office hours": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 3, 26, 8, 30, 0, 0, time.UTC), // Fri 09:30 CET
+			Expected: true,
+		},
+		"before office hours in winter time": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 3, 26, 7, 30, 0, 0, time.UTC), // Fri 08:30 CET
+			Expected: false,
+		},
+		"same utc time within office hours after switch to summer time": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 3, 29, 7, 30, 0, 0, time.UTC), // Mon 09:30 CEST
+			Expected: true,
+		},
+		"end of office hours is exclusive": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 3, 29, 15, 0, 0, 0, time.UTC), // Mon 17:00 CEST
+			Expected: false,
+		},
+		"weekend on day of switch to summer time": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 3, 28, 10, 0, 0, 0, berlin),
+			Expected: false,
+		},
+		"within office hours in summer time": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 10, 29, 7, 30, 0, 0, time.UTC), // Fri 09:30 CEST
+			Expected: true,
+		},
+		"same utc time before office hours after switch to winter time": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: officeHours, Location: berlin},
+			Time:     time.Date(2021, 11, 1, 7, 30, 0, 0, time.UTC), // Mon 08:30 CET
+			Expected: false,
+		},
+		"night shift after midnight belongs to previous day": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: nightShift, Location: berlin},
+			Time:     time.Date(2021, 3, 27, 1, 30, 0, 0, berlin), // Sat 01:30, Fri night
+			Expected: true,
+		},
+		"night shift after midnight of sunday": {
+			Config:   filter.ScheduleConfig{Days: weekdays, Windows: nightShift, Location: berlin},
+			Time:     time.Date(2021, 3, 29, 1, 30, 0, 0, berlin), // Mon 01:30, Sun night
+			Expected: false,
+		},
+		"night shift before switch to summer time": {
+			Config:   filter.ScheduleConfig{Windows: nightShift, Location: berlin},
+			Time:     time.Date(2021, 3, 28, 0, 30, 0, 0, time.UTC), // Sun 01:30 CET
+			Expected: true,
+		},
+		"night shift after switch to summer time": {
+			Config:   filter.ScheduleConfig{Windows: nightShift, Location: berlin},
+			Time:     time.Date(2021, 3, 28, 1, 30, 0, 0, time.UTC), // Sun 03:30 CEST
+			Expected: false,
+		},
+		"repeated hour at switch to winter time first occurrence": {
+			Config: filter.ScheduleConfig{
+				Windows:  []filter.TimeWindow{{Start: 1 * time.Hour, End: 2 * time.Hour}},
+				Location: newYork,
+			},
+			Time:     time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+			Expected: true,
+		},
+		"repeated hour at switch to winter time second occurrence": {
+			Config: filter.ScheduleConfig{
+				Windows:  []filter.TimeWindow{{Start: 1 * time.Hour, End: 2 * time.Hour}},
+				Location: newYork,
+			},
+			Time:     time.Date(2021, 11, 7, 6, 30, 0, 0, time.UTC), // 01:30 EST
+			Expected: true,
+		},
+		"timezone changes weekday": {
+			Config:   filter.ScheduleConfig{Days: []time.Weekday{time.Friday}, Location: newYork},
+			Time:     time.Date(2021, 3, 27, 2, 0, 0, 0, time.UTC), // Fri 22:00 EDT
+			Expected: true,
+		},
+		"holiday": {
+			Config: filter.ScheduleConfig{
+				Days:     weekdays,
+				Windows:  officeHours,
+				Location: berlin,
+				Holidays: map[string]struct{}{"2021-12-24": {}},
+			},
+			Time:     time.Date(2021, 12, 24, 10, 0, 0, 0, berlin),
+			Expected: false,
+		},
+		"empty schedule": {
+			Time:     time.Date(2021, 12, 24, 10, 0, 0, 0, berlin),
+			Expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, test.Config.Within(test.Time))
+		})
+	}
+}
+
+func TestWithSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	within := float64(time.Date(2021, 3, 26, 10, 0, 0, 0, berlin).Unix())
+	outside := float64(time.Date(2021, 3, 26, 20, 0, 0, 0, berlin).Unix())
+
+	schedule := filter.ScheduleConfig{
+		Windows:  []filter.TimeWindow{{Start: 9 * time.Hour, End: 17 * time.Hour}},
+		Location: berlin,
+	}
+
+	tests := map[string]struct {
+		OutsideAPIKey  string
+		OutsideProject string
+		Expected       []heartbeat.Heartbeat
+	}{
+		"drop": {
+			Expected: []heartbeat.Heartbeat{
+				{Project: heartbeat.String("wakatime"), Time: within},
+			},
+		},
+		"re-tag": {
+			OutsideAPIKey:  "00000000-0000-4000-8000-000000000001",
+			OutsideProject: "after-hours",
+			Expected: []heartbeat.Heartbeat{
+				{Project: heartbeat.String("wakatime"), Time: within},
+				{
+					APIKey:  "00000000-0000-4000-8000-000000000001",
+					Project: heartbeat.String("after-hours"),
+					Time:    outside,
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := schedule
+			config.OutsideAPIKey = test.OutsideAPIKey
+			config.OutsideProject = test.OutsideProject
+
+			opt := filter.WithSchedule(config)
+
+			handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+				assert.Equal(t, test.Expected, hh)
+
+				return nil, nil
+			})
+
+			_, err := handle([]heartbeat.Heartbeat{
+				{Project: heartbeat.String("wakatime"), Time: within},
+				{Project: heartbeat.String("wakatime"), Time: outside},
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := map[string][]time.Weekday{
+		"mon-fri":          {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		"Sat, sunday":      {time.Saturday, time.Sunday},
+		"fri-mon":          {time.Friday, time.Saturday, time.Sunday, time.Monday},
+		"tues-wed, friday": {time.Tuesday, time.Wednesday, time.Friday},
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			days, err := filter.ParseWeekdays(input)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, days)
+		})
+	}
+}
+
+func TestParseWeekdays_Err(t *testing.T) {
+	for _, input := range []string{"mo-fr", "monx", "mon-"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := filter.ParseWeekdays(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseTimeWindows(t *testing.T) {
+	windows, err := filter.ParseTimeWindows("09:00-12:00, 13:00-17:30, 22:00-02:00, 18:00-24:00")
+	require.NoError(t, err)
+
+	assert.Equal(t, []filter.TimeWindow{
+		{Start: 9 * time.Hour, End: 12 * time.Hour},
+		{Start: 13 * time.Hour, End: 17*time.Hour + 30*time.Minute},
+		{Start: 22 * time.Hour, End: 2 * time.Hour},
+		{Start: 18 * time.Hour, End: 24 * time.Hour},
+	}, windows)
+}
+
+func TestParseTimeWindows_Err(t *testing.T) {
+	for _, input := range []string{"09:00", "9-17", "09:00-25:00", "09:60-10:00", "10:00-10:00"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := filter.ParseTimeWindows(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadHolidays(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, "holidays.txt")
+
+	err = ioutil.WriteFile(fp, []byte("# public holidays\n2021-12-24 Christmas Eve\n\n2021-12-25\n"), 0600)
+	require.NoError(t, err)
+
+	holidays, err := filter.LoadHolidays(fp)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]struct{}{
+		"2021-12-24": {},
+		"2021-12-25": {},
+	}, holidays)
+
+	err = ioutil.WriteFile(fp, []byte("2021-12-24\n24.12.2021\n"), 0600)
+	require.NoError(t, err)
+
+	_, err = filter.LoadHolidays(fp)
+	assert.EqualError(t, err, `invalid date "24.12.2021" in holidays file at line 2`)
+}
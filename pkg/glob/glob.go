@@ -0,0 +1,121 @@
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Compile converts a glob pattern into a regular expression, which matches
+// from the start of the subject. Every wildcard becomes a capture group, so
+// that matched parts can be referenced in templates. Supported syntax:
+//
+//	**     matches any characters, including path separators
+//	*      matches any characters, except path separators
+//	?      matches a single character, except path separators
+//	[abc]  matches one of the characters; [!abc] negates the class
+//	{a,b}  matches one of the comma separated alternatives
+//	\*     escapes a special character
+//
+// A pattern without a trailing slash matches the path itself as well as
+// everything below it, e.g. '/home/user/*' matches '/home/user/src/main.go'.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	expr, err := ToRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return regexp.Compile(expr)
+}
+
+// ToRegex converts a glob pattern into the source of a regular expression.
+// See Compile for the supported syntax.
+func ToRegex(pattern string) (string, error) {
+	var (
+		b     strings.Builder
+		depth int
+	)
+
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch {
+		case c == '\\':
+			if i+1 >= len(pattern) {
+				return "", fmt.Errorf("trailing escape character in glob pattern %q", pattern)
+			}
+
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+			// consume a following separator, so that '**/' also matches zero directories
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				i++
+				b.WriteString("((?:.*/)?)")
+
+				continue
+			}
+
+			b.WriteString("(.*)")
+		case c == '*':
+			b.WriteString("([^/]*)")
+		case c == '?':
+			b.WriteString("([^/])")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class in glob pattern %q", pattern)
+			}
+
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("([" + strings.ReplaceAll(class, `\`, `\\`) + "])")
+
+			i += end + 1
+		case c == '{':
+			depth++
+
+			b.WriteString("(?:")
+		case c == '}' && depth > 0:
+			depth--
+
+			b.WriteString(")")
+		case c == ',' && depth > 0:
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if depth > 0 {
+		return "", fmt.Errorf("unterminated brace expansion in glob pattern %q", pattern)
+	}
+
+	if !strings.HasSuffix(pattern, "/") {
+		b.WriteString("(?:/|$)")
+	}
+
+	return b.String(), nil
+}
+
+// QuoteMeta escapes all glob special characters in s, so that the returned
+// pattern matches the literal text.
+func QuoteMeta(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\*?[]{},`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
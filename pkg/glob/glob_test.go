@@ -0,0 +1,131 @@
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/glob"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := map[string]struct {
+		Pattern  string
+		Subject  string
+		Expected bool
+	}{
+		"exact": {
+			Pattern:  "/home/user/projects/foo",
+			Subject:  "/home/user/projects/foo",
+			Expected: true,
+		},
+		"below directory": {
+			Pattern:  "/home/user/projects/foo",
+			Subject:  "/home/user/projects/foo/src/main.go",
+			Expected: true,
+		},
+		"prefix of directory name": {
+			Pattern:  "/home/user/projects/foo",
+			Subject:  "/home/user/projects/foobar/main.go",
+			Expected: false,
+		},
+		"star": {
+			Pattern:  "/home/user/*/main.go",
+			Subject:  "/home/user/projects/main.go",
+			Expected: true,
+		},
+		"star does not cross separator": {
+			Pattern:  "/home/*/main.go",
+			Subject:  "/home/user/projects/main.go",
+			Expected: false,
+		},
+		"double star": {
+			Pattern:  "/home/**/main.go",
+			Subject:  "/home/user/projects/main.go",
+			Expected: true,
+		},
+		"double star zero directories": {
+			Pattern:  "/home/**/main.go",
+			Subject:  "/home/main.go",
+			Expected: true,
+		},
+		"question mark": {
+			Pattern:  "/src/v?/main.go",
+			Subject:  "/src/v2/main.go",
+			Expected: true,
+		},
+		"character class": {
+			Pattern:  "/src/v[0-9]/main.go",
+			Subject:  "/src/v2/main.go",
+			Expected: true,
+		},
+		"negated character class": {
+			Pattern:  "/src/v[!0-9]/main.go",
+			Subject:  "/src/v2/main.go",
+			Expected: false,
+		},
+		"braces": {
+			Pattern:  "/src/main.{go,rs}",
+			Subject:  "/src/main.rs",
+			Expected: true,
+		},
+		"braces no match": {
+			Pattern:  "/src/main.{go,rs}",
+			Subject:  "/src/main.py",
+			Expected: false,
+		},
+		"escaped": {
+			Pattern:  `/src/\*.go`,
+			Subject:  "/src/*.go",
+			Expected: true,
+		},
+		"regex meta characters are literal": {
+			Pattern:  "/src/main.go",
+			Subject:  "/src/mainxgo",
+			Expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			re, err := glob.Compile(test.Pattern)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, re.MatchString(test.Subject))
+		})
+	}
+}
+
+func TestCompile_CaptureGroups(t *testing.T) {
+	re, err := glob.Compile("/home/user/work/*/")
+	require.NoError(t, err)
+
+	matches := re.FindStringSubmatch("/home/user/work/acme/src/main.go")
+	require.Len(t, matches, 2)
+
+	assert.Equal(t, "acme", matches[1])
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unterminated class":  "/src/[abc",
+		"unterminated braces": "/src/{a,b",
+		"trailing escape":     `/src/\`,
+	}
+
+	for name, pattern := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := glob.Compile(pattern)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestQuoteMeta(t *testing.T) {
+	re, err := glob.Compile(glob.QuoteMeta("/home/{user}/*[1]") + "/*.go")
+	require.NoError(t, err)
+
+	assert.True(t, re.MatchString("/home/{user}/*[1]/main.go"))
+	assert.False(t, re.MatchString("/home/user/foo1/main.go"))
+}
@@ -11,19 +11,24 @@ import (
 
 // Heartbeat is a structure representing activity for a user on a some entity.
 type Heartbeat struct {
-	Branch         *string    `json:"branch"`
-	Category       Category   `json:"category"`
-	CursorPosition *int       `json:"cursorpos"`
-	Dependencies   []string   `json:"dependencies"`
-	Entity         string     `json:"entity"`
-	EntityType     EntityType `json:"type"`
-	IsWrite        *bool      `json:"is_write"`
-	Language       *string    `json:"language"`
-	LineNumber     *int       `json:"lineno"`
-	Lines          *int       `json:"lines"`
-	Project        *string    `json:"project"`
-	Time           float64    `json:"time"`
-	UserAgent      string     `json:"user_agent"`
+	APIKey           string     `json:"-"`
+	Branch           *string    `json:"branch"`
+	Category         Category   `json:"category"`
+	CursorPosition   *int       `json:"cursorpos"`
+	Dependencies     []string   `json:"dependencies"`
+	Entity           string     `json:"entity"`
+	EntityType       EntityType `json:"type"`
+	IsWrite          *bool      `json:"is_write"`
+	IssueKey         *string    `json:"issue_key,omitempty"`
+	Language         *string    `json:"language"`
+	LineNumber       *int       `json:"lineno"`
+	Lines            *int       `json:"lines"`
+	Project          *string    `json:"project"`
+	ProjectPath      string     `json:"-"`
+	ProjectHidden    bool       `json:"-"`
+	ProjectRootCount *int       `json:"project_root_count,omitempty"`
+	Time             float64    `json:"time"`
+	UserAgent        string     `json:"user_agent"`
 }
 
 // ID returns an ID generated from the heartbeat data.
@@ -0,0 +1,244 @@
+package heartbeat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+)
+
+// RuleSubject is the heartbeat value a sanitize rule pattern is matched against.
+type RuleSubject string
+
+const (
+	// RuleSubjectBranch matches the branch name.
+	RuleSubjectBranch RuleSubject = "branch"
+	// RuleSubjectFile matches the entity of file heartbeats only.
+	RuleSubjectFile RuleSubject = "file"
+	// RuleSubjectProject matches the project name.
+	RuleSubjectProject RuleSubject = "project"
+)
+
+// RuleField is a heartbeat field a sanitize rule can drop or hash.
+type RuleField string
+
+const (
+	// RuleFieldBranch is the branch and the issue key extracted from it.
+	RuleFieldBranch RuleField = "branch"
+	// RuleFieldCursorPosition is the cursor position.
+	RuleFieldCursorPosition RuleField = "cursorpos"
+	// RuleFieldDependencies is the list of dependencies.
+	RuleFieldDependencies RuleField = "dependencies"
+	// RuleFieldEntity is the entity. When dropped, it is replaced by "HIDDEN" and, for
+	// file entities, its extension.
+	RuleFieldEntity RuleField = "entity"
+	// RuleFieldLanguage is the language.
+	RuleFieldLanguage RuleField = "language"
+	// RuleFieldLineNumber is the line number.
+	RuleFieldLineNumber RuleField = "lineno"
+	// RuleFieldLines is the number of lines.
+	RuleFieldLines RuleField = "lines"
+)
+
+// SanitizeRule drops or hashes heartbeat fields, if its pattern matches the subject.
+type SanitizeRule struct {
+	Subject RuleSubject
+	Pattern matcher.Matcher
+	// Drop contains the fields to remove.
+	Drop []RuleField
+	// Hash contains the fields to replace by their keyed hash.
+	Hash []RuleField
+	// Rule is the original config entry, used for debugging output.
+	Rule string
+}
+
+// ParseSanitizeRule parses a sanitize rule of the form
+// "subject pattern | drop=field,field | hash=field". Subject is one of
+// branch, file or project. Pattern is a regular expression or, with a "glob:"
+// prefix, a glob pattern and can itself contain "|".
+func ParseSanitizeRule(s string) (SanitizeRule, error) {
+	s = strings.TrimSpace(s)
+
+	fields := strings.SplitN(s, " ", 2)
+	if len(fields) != 2 {
+		return SanitizeRule{}, fmt.Errorf("missing pattern in rule %q", s)
+	}
+
+	rule := SanitizeRule{Rule: s}
+
+	switch subject := RuleSubject(strings.ToLower(fields[0])); subject {
+	case RuleSubjectBranch, RuleSubjectFile, RuleSubjectProject:
+		rule.Subject = subject
+	default:
+		return SanitizeRule{}, fmt.Errorf("invalid subject %q. must be one of branch, file, project", fields[0])
+	}
+
+	// options are trailing parts, so patterns can contain "|"
+	parts := strings.Split(fields[1], "|")
+
+	for len(parts) > 1 {
+		option := strings.TrimSpace(parts[len(parts)-1])
+		if !strings.HasPrefix(option, "drop=") && !strings.HasPrefix(option, "hash=") {
+			break
+		}
+
+		if err := rule.parseOption(option); err != nil {
+			return SanitizeRule{}, err
+		}
+
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(rule.Drop) == 0 && len(rule.Hash) == 0 {
+		return SanitizeRule{}, fmt.Errorf("missing drop or hash option in rule %q", s)
+	}
+
+	pattern, err := matcher.Compile(strings.TrimSpace(strings.Join(parts, "|")))
+	if err != nil {
+		return SanitizeRule{}, fmt.Errorf("invalid pattern in rule %q: %s", s, err)
+	}
+
+	rule.Pattern = pattern
+
+	return rule, nil
+}
+
+// parseOption parses a "drop=" or "hash=" option and adds its fields to the rule.
+func (r *SanitizeRule) parseOption(option string) error {
+	kv := strings.SplitN(option, "=", 2)
+
+	for _, f := range strings.Split(kv[1], ",") {
+		field := RuleField(strings.ToLower(strings.TrimSpace(f)))
+
+		switch field {
+		case RuleFieldBranch, RuleFieldDependencies, RuleFieldEntity, RuleFieldLanguage:
+		case RuleFieldCursorPosition, RuleFieldLineNumber, RuleFieldLines:
+			if kv[0] == "hash" {
+				return fmt.Errorf("field %q can not be hashed", field)
+			}
+		default:
+			return fmt.Errorf("invalid field %q. must be one of branch, cursorpos, dependencies,"+
+				" entity, language, lineno, lines", f)
+		}
+
+		if kv[0] == "hash" {
+			r.Hash = append(r.Hash, field)
+		} else {
+			r.Drop = append(r.Drop, field)
+		}
+	}
+
+	return nil
+}
+
+// Matches checks the rule pattern against the rule subject of a heartbeat.
+// File rules never match heartbeats of other entity types.
+func (r SanitizeRule) Matches(h Heartbeat) bool {
+	var subject *string
+
+	switch r.Subject {
+	case RuleSubjectBranch:
+		subject = h.Branch
+	case RuleSubjectFile:
+		if h.EntityType == FileType {
+			subject = &h.Entity
+		}
+	case RuleSubjectProject:
+		subject = h.Project
+	}
+
+	return subject != nil && r.Pattern != nil && r.Pattern.MatchString(*subject)
+}
+
+// applyRules applies all rules matching the original heartbeat in order to h.
+// Fields to hash are dropped, if no key is set.
+func applyRules(h, original Heartbeat, config SanitizeConfig) Heartbeat {
+	for _, rule := range config.Rules {
+		if !rule.Matches(original) {
+			continue
+		}
+
+		config.Trace.Add("sanitize", "%s matches [sanitize] rule %q", rule.Subject, rule.Rule)
+
+		for _, field := range rule.Drop {
+			h = dropField(h, field)
+		}
+
+		for _, field := range rule.Hash {
+			if len(config.RuleHashKey) == 0 {
+				config.Trace.Add("sanitize", "no rule hash key configured. dropping %s instead", field)
+
+				h = dropField(h, field)
+
+				continue
+			}
+
+			h = hashField(h, field, config.RuleHashKey)
+		}
+	}
+
+	return h
+}
+
+// dropField removes a field from a heartbeat.
+func dropField(h Heartbeat, field RuleField) Heartbeat {
+	switch field {
+	case RuleFieldBranch:
+		h.Branch = nil
+		h.IssueKey = nil
+	case RuleFieldCursorPosition:
+		h.CursorPosition = nil
+	case RuleFieldDependencies:
+		h.Dependencies = nil
+	case RuleFieldEntity:
+		if h.EntityType == FileType {
+			h.Entity = "HIDDEN" + filepath.Ext(h.Entity)
+		} else {
+			h.Entity = "HIDDEN"
+		}
+	case RuleFieldLanguage:
+		h.Language = nil
+	case RuleFieldLineNumber:
+		h.LineNumber = nil
+	case RuleFieldLines:
+		h.Lines = nil
+	}
+
+	return h
+}
+
+// hashField replaces a field of a heartbeat by its keyed hash.
+func hashField(h Heartbeat, field RuleField, key []byte) Heartbeat {
+	switch field {
+	case RuleFieldBranch:
+		if h.Branch != nil {
+			h.Branch = String(hashComponent(*h.Branch, key))
+		}
+
+		h.IssueKey = nil
+	case RuleFieldDependencies:
+		if len(h.Dependencies) > 0 {
+			hashed := make([]string, len(h.Dependencies))
+			for i, dep := range h.Dependencies {
+				hashed[i] = hashComponent(dep, key)
+			}
+
+			h.Dependencies = hashed
+		}
+	case RuleFieldEntity:
+		if h.EntityType == FileType {
+			h.Entity = HashFilePath(h.Entity, key)
+		} else {
+			h.Entity = hashComponent(h.Entity, key)
+		}
+	case RuleFieldLanguage:
+		if h.Language != nil {
+			h.Language = String(hashComponent(*h.Language, key))
+		}
+	default:
+		return dropField(h, field)
+	}
+
+	return h
+}
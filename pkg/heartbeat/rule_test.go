@@ -0,0 +1,267 @@
+package heartbeat_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSanitizeRule(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Expected heartbeat.SanitizeRule
+	}{
+		"drop": {
+			Input: "project secret-.* | drop=lineno,cursorpos",
+			Expected: heartbeat.SanitizeRule{
+				Subject: heartbeat.RuleSubjectProject,
+				Pattern: regexp.MustCompile("secret-.*"),
+				Drop:    []heartbeat.RuleField{heartbeat.RuleFieldLineNumber, heartbeat.RuleFieldCursorPosition},
+				Rule:    "project secret-.* | drop=lineno,cursorpos",
+			},
+		},
+		"drop and hash": {
+			Input: "file glob:**/*.env | hash=entity | drop=dependencies",
+			Expected: heartbeat.SanitizeRule{
+				Subject: heartbeat.RuleSubjectFile,
+				Pattern: matcher.MustCompile("glob:**/*.env"),
+				Drop:    []heartbeat.RuleField{heartbeat.RuleFieldDependencies},
+				Hash:    []heartbeat.RuleField{heartbeat.RuleFieldEntity},
+				Rule:    "file glob:**/*.env | hash=entity | drop=dependencies",
+			},
+		},
+		"pattern with alternation": {
+			Input: "branch feature/(a|b) | drop=branch",
+			Expected: heartbeat.SanitizeRule{
+				Subject: heartbeat.RuleSubjectBranch,
+				Pattern: regexp.MustCompile("feature/(a|b)"),
+				Drop:    []heartbeat.RuleField{heartbeat.RuleFieldBranch},
+				Rule:    "branch feature/(a|b) | drop=branch",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rule, err := heartbeat.ParseSanitizeRule(test.Input)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected.Subject, rule.Subject)
+			assert.Equal(t, test.Expected.Pattern.String(), rule.Pattern.String())
+			assert.Equal(t, test.Expected.Drop, rule.Drop)
+			assert.Equal(t, test.Expected.Hash, rule.Hash)
+			assert.Equal(t, test.Expected.Rule, rule.Rule)
+		})
+	}
+}
+
+func TestParseSanitizeRule_Err(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Expected string
+	}{
+		"missing pattern": {
+			Input:    "project",
+			Expected: `missing pattern in rule "project"`,
+		},
+		"invalid subject": {
+			Input:    "language go | drop=lineno",
+			Expected: `invalid subject "language". must be one of branch, file, project`,
+		},
+		"missing option": {
+			Input:    "project secret",
+			Expected: `missing drop or hash option in rule "project secret"`,
+		},
+		"invalid field": {
+			Input:    "project secret | drop=time",
+			Expected: `invalid field "time"`,
+		},
+		"hash numeric field": {
+			Input:    "project secret | hash=lineno",
+			Expected: `field "lineno" can not be hashed`,
+		},
+		"invalid pattern": {
+			Input:    "project secret( | drop=lineno",
+			Expected: `invalid pattern in rule "project secret( | drop=lineno"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := heartbeat.ParseSanitizeRule(test.Input)
+			require.Error(t, err)
+
+			assert.Contains(t, err.Error(), test.Expected)
+		})
+	}
+}
+
+func TestSanitize_Rules(t *testing.T) {
+	rules := []heartbeat.SanitizeRule{
+		{
+			Subject: heartbeat.RuleSubjectProject,
+			Pattern: regexp.MustCompile("^wakatime$"),
+			Drop:    []heartbeat.RuleField{heartbeat.RuleFieldLineNumber, heartbeat.RuleFieldCursorPosition},
+		},
+		{
+			Subject: heartbeat.RuleSubjectFile,
+			Pattern: matcher.MustCompile("glob:/tmp/*.go"),
+			Drop:    []heartbeat.RuleField{heartbeat.RuleFieldLanguage},
+			Hash:    []heartbeat.RuleField{heartbeat.RuleFieldDependencies},
+		},
+		{
+			Subject: heartbeat.RuleSubjectBranch,
+			Pattern: regexp.MustCompile("^other$"),
+			Drop:    []heartbeat.RuleField{heartbeat.RuleFieldBranch},
+		},
+	}
+
+	h := testHeartbeat()
+	h.IssueKey = heartbeat.String("PROJ-123")
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		RuleHashKey: []byte("secret"),
+		Rules:       rules,
+	})
+
+	assert.Equal(t, heartbeat.Heartbeat{
+		Branch:   heartbeat.String("heartbeat"),
+		Category: heartbeat.CodingCategory,
+		Dependencies: []string{
+			heartbeat.HashFilePath("dep1", []byte("secret")),
+			heartbeat.HashFilePath("dep2", []byte("secret")),
+		},
+		Entity:     "/tmp/main.go",
+		EntityType: heartbeat.FileType,
+		IsWrite:    heartbeat.Bool(true),
+		IssueKey:   heartbeat.String("PROJ-123"),
+		Lines:      heartbeat.Int(100),
+		Project:    heartbeat.String("wakatime"),
+		Time:       1585598060,
+		UserAgent:  "wakatime/13.0.7",
+	}, r)
+}
+
+func TestSanitize_Rules_HashWithoutKey(t *testing.T) {
+	h := testHeartbeat()
+	h.IssueKey = heartbeat.String("PROJ-123")
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		Rules: []heartbeat.SanitizeRule{
+			{
+				Subject: heartbeat.RuleSubjectBranch,
+				Pattern: regexp.MustCompile("^heart"),
+				Hash:    []heartbeat.RuleField{heartbeat.RuleFieldBranch, heartbeat.RuleFieldEntity},
+			},
+		},
+	})
+
+	assert.Nil(t, r.Branch)
+	assert.Nil(t, r.IssueKey)
+	assert.Equal(t, "HIDDEN.go", r.Entity)
+}
+
+func TestSanitize_Rules_HashKeyIndependentOfFileNames(t *testing.T) {
+	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
+		FilePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		RuleHashKey:  []byte("secret"),
+		Rules: []heartbeat.SanitizeRule{
+			{
+				Subject: heartbeat.RuleSubjectBranch,
+				Pattern: regexp.MustCompile("^heartbeat$"),
+				Hash:    []heartbeat.RuleField{heartbeat.RuleFieldLanguage},
+			},
+		},
+	})
+
+	// hidden file names are not hashed with the rule hash key
+	assert.Equal(t, "HIDDEN.go", r.Entity)
+	assert.Equal(t, heartbeat.String(heartbeat.HashFilePath("golang", []byte("secret"))), r.Language)
+}
+
+func TestSanitize_Rules_MatchOriginal(t *testing.T) {
+	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
+		FilePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		Rules: []heartbeat.SanitizeRule{
+			{
+				Subject: heartbeat.RuleSubjectFile,
+				Pattern: regexp.MustCompile("^/tmp/main.go$"),
+				Drop:    []heartbeat.RuleField{heartbeat.RuleFieldLanguage},
+			},
+		},
+	})
+
+	assert.Equal(t, "HIDDEN.go", r.Entity)
+	assert.Nil(t, r.Language)
+}
+
+func TestSanitize_Rules_EntityTypeNotFile(t *testing.T) {
+	tests := map[string]struct {
+		Entity     string
+		EntityType heartbeat.EntityType
+	}{
+		"app": {
+			Entity:     "Slack",
+			EntityType: heartbeat.AppType,
+		},
+		"domain": {
+			Entity:     "https://wakatime.com",
+			EntityType: heartbeat.DomainType,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHeartbeat()
+			h.Entity = test.Entity
+			h.EntityType = test.EntityType
+
+			r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+				Rules: []heartbeat.SanitizeRule{
+					{
+						Subject: heartbeat.RuleSubjectProject,
+						Pattern: regexp.MustCompile("^wakatime$"),
+						Drop:    []heartbeat.RuleField{heartbeat.RuleFieldEntity, heartbeat.RuleFieldBranch},
+					},
+					{
+						Subject: heartbeat.RuleSubjectFile,
+						Pattern: regexp.MustCompile(".*"),
+						Drop:    []heartbeat.RuleField{heartbeat.RuleFieldLanguage},
+					},
+				},
+			})
+
+			assert.Equal(t, "HIDDEN", r.Entity)
+			assert.Nil(t, r.Branch)
+			assert.Nil(t, r.IssueKey)
+
+			// file rules only match file entities
+			assert.Equal(t, heartbeat.String("golang"), r.Language)
+		})
+	}
+}
+
+func TestSanitize_Rules_EntityTypeNotFile_Hash(t *testing.T) {
+	h := testHeartbeat()
+	h.Entity = "Slack"
+	h.EntityType = heartbeat.AppType
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		RuleHashKey: []byte("secret"),
+		Rules: []heartbeat.SanitizeRule{
+			{
+				Subject: heartbeat.RuleSubjectBranch,
+				Pattern: regexp.MustCompile("^heartbeat$"),
+				Hash:    []heartbeat.RuleField{heartbeat.RuleFieldEntity},
+			},
+		},
+	})
+
+	assert.Regexp(t, "^[a-f0-9]{16}$", r.Entity)
+	assert.Equal(t, heartbeat.HashFilePath("Slack", []byte("secret")), r.Entity)
+}
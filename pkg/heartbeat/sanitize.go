@@ -1,20 +1,50 @@
 package heartbeat
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"path/filepath"
-	"regexp"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 )
 
 // SanitizeConfig defines how a heartbeat should be sanitized.
 type SanitizeConfig struct {
-	// BranchPatterns will be matched against the branch and if matching, will obfuscate it.
-	BranchPatterns []*regexp.Regexp
+	// BranchPatterns will be matched against the branch and if matching, will obfuscate it
+	// and the issue key extracted from it.
+	BranchPatterns []matcher.Matcher
 	// FilePatterns will be matched against a file entities name and if matching, will obfuscate
 	// the file name and common heartbeat meta data (cursor position, dependencies, line number and lines).
-	FilePatterns []*regexp.Regexp
+	FilePatterns []matcher.Matcher
 	// ProjectPatterns will be matched against the project name and if matching, will obfuscate
 	// common heartbeat meta data (cursor position, dependencies, line number and lines).
-	ProjectPatterns []*regexp.Regexp
+	ProjectPatterns []matcher.Matcher
+	// FileNameHashKey is an optional key. If set, file names matching FilePatterns
+	// are not replaced by "HIDDEN", but each path component is replaced by its keyed
+	// hash, preserving directory structure and extension. See HashFilePath.
+	FileNameHashKey []byte
+	// HideProjectFolder rewrites file entities to be relative to the project root
+	// folder and records the number of stripped path segments in ProjectRootCount.
+	HideProjectFolder bool
+	// DomainPatterns will be matched against the host of domain entities and if
+	// matching, will replace the entity by "HIDDEN".
+	DomainPatterns []matcher.Matcher
+	// URLMode defines how much of other domain entities is sent.
+	URLMode URLMode
+	// SecretURLParams are removed from domain entities in addition to DefaultSecretURLParams.
+	SecretURLParams []string
+	// Rules are applied in order after the patterns above to heartbeats of all
+	// entity types. Rules are matched against the heartbeat before any
+	// sanitization.
+	Rules []SanitizeRule
+	// RuleHashKey is the key fields are hashed with by Rules. It is independent
+	// of FileNameHashKey, so that hash rules do not change how file names
+	// matching FilePatterns are hidden.
+	RuleHashKey []byte
+	// Trace optionally records which sanitization rules applied.
+	Trace *Trace
 }
 
 // WithSanitization initializes and returns a heartbeat handle option, which
@@ -38,30 +68,179 @@ func Sanitize(h Heartbeat, config SanitizeConfig) Heartbeat {
 		h.Dependencies = nil
 	}
 
-	if h.EntityType != FileType {
-		return h
+	original := h
+
+	switch h.EntityType {
+	case FileType:
+	case DomainType:
+		return applyRules(sanitizeDomain(h, config), original, config)
+	default:
+		return applyRules(h, original, config)
+	}
+
+	filePattern, hideFile := matchPattern(h.Entity, config.FilePatterns)
+
+	if config.HideProjectFolder {
+		h = sanitizeProjectFolder(h, config)
 	}
 
+	var (
+		projectPattern matcher.Matcher
+		hideProject    bool
+	)
+
+	if h.Project != nil {
+		projectPattern, hideProject = matchPattern(*h.Project, config.ProjectPatterns)
+	}
+
+	// the real name of an obfuscated project matched the patterns during project detection
+	hiddenByDetection := !hideProject && h.ProjectHidden
+
 	switch {
-	case shouldSanitize(h.Entity, config.FilePatterns):
+	case hideFile && len(config.FileNameHashKey) > 0:
+		config.Trace.Add("sanitize", "entity matches hide_file_names pattern %q. hashing file path and hiding metadata",
+			filePattern.String())
+
+		h.Entity = HashFilePath(h.Entity, config.FileNameHashKey)
+		h = santizeMetaData(h)
+		h = sanitizeBranch(h, config, true)
+	case hideFile:
+		config.Trace.Add("sanitize", "entity matches hide_file_names pattern %q. hiding file name and metadata",
+			filePattern.String())
+
 		h.Entity = "HIDDEN" + filepath.Ext(h.Entity)
 		h = santizeMetaData(h)
+		h = sanitizeBranch(h, config, true)
+	case hideProject:
+		config.Trace.Add("sanitize", "project matches hide_project_names pattern %q. hiding metadata",
+			projectPattern.String())
+
+		h = santizeMetaData(h)
+		h = sanitizeBranch(h, config, true)
+	case hiddenByDetection:
+		config.Trace.Add("sanitize", "project name was obfuscated by hide_project_names. hiding metadata")
 
-		if h.Branch != nil && (len(config.BranchPatterns) == 0 || shouldSanitize(*h.Branch, config.BranchPatterns)) {
-			h.Branch = nil
-		}
-	case h.Project != nil && shouldSanitize(*h.Project, config.ProjectPatterns):
 		h = santizeMetaData(h)
-		if h.Branch != nil && (len(config.BranchPatterns) == 0 || shouldSanitize(*h.Branch, config.BranchPatterns)) {
-			h.Branch = nil
+		h = sanitizeBranch(h, config, true)
+	default:
+		h = sanitizeBranch(h, config, false)
+	}
+
+	return applyRules(h, original, config)
+}
+
+// sanitizeBranch removes the branch and issue key, if the branch matches
+// the branch patterns. If hidden is true, the entity or project was already
+// hidden and the branch is also removed, if no branch patterns are configured.
+func sanitizeBranch(h Heartbeat, config SanitizeConfig, hidden bool) Heartbeat {
+	if h.Branch == nil {
+		return h
+	}
+
+	if hidden && len(config.BranchPatterns) == 0 {
+		config.Trace.Add("sanitize", "hiding branch, because no hide_branch_names patterns are configured")
+	} else if p, ok := matchPattern(*h.Branch, config.BranchPatterns); ok {
+		config.Trace.Add("sanitize", "branch matches hide_branch_names pattern %q. hiding branch", p.String())
+	} else {
+		return h
+	}
+
+	h.Branch = nil
+	h.IssueKey = nil
+
+	return h
+}
+
+// sanitizeProjectFolder makes the entity relative to the project root folder,
+// if the entity is located inside of it.
+func sanitizeProjectFolder(h Heartbeat, config SanitizeConfig) Heartbeat {
+	if h.ProjectPath == "" {
+		return h
+	}
+
+	rel, ok := relativeToFolder(h.Entity, h.ProjectPath)
+	if !ok {
+		// project folders are resolved, so retry with resolved symlinks
+		if fp, err := filepath.EvalSymlinks(h.Entity); err == nil {
+			rel, ok = relativeToFolder(fp, h.ProjectPath)
 		}
-	case h.Branch != nil && shouldSanitize(*h.Branch, config.BranchPatterns):
-		h.Branch = nil
 	}
 
+	if !ok {
+		config.Trace.Add("sanitize", "entity is not inside of project folder %q. keeping absolute path", h.ProjectPath)
+		return h
+	}
+
+	count := countPathSegments(h.ProjectPath)
+
+	config.Trace.Add("sanitize", "stripping project folder from entity. %d path segment(s) removed", count)
+
+	h.Entity = rel
+	h.ProjectRootCount = &count
+
 	return h
 }
 
+// relativeToFolder returns fp relative to folder with forward slashes, if fp is inside of folder.
+func relativeToFolder(fp, folder string) (string, bool) {
+	rel, err := filepath.Rel(folder, fp)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
+
+// countPathSegments returns the number of non empty path segments of fp, including a volume name.
+func countPathSegments(fp string) int {
+	volume := filepath.VolumeName(fp)
+	segments := strings.FieldsFunc(fp[len(volume):], func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+
+	if volume != "" {
+		return len(segments) + 1
+	}
+
+	return len(segments)
+}
+
+// HashFilePath replaces each component of the file path fp by the hex encoded,
+// truncated HMAC-SHA256 of the component with key. Separators, a leading
+// volume name and the extension of the file name are preserved, so that the
+// same file maps to the same opaque path with the same key.
+func HashFilePath(fp string, key []byte) string {
+	volume := filepath.VolumeName(fp)
+	components := strings.FieldsFunc(fp[len(volume):], func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+
+	for i, c := range components {
+		var ext string
+		if i == len(components)-1 {
+			ext = filepath.Ext(c)
+			c = strings.TrimSuffix(c, ext)
+		}
+
+		components[i] = hashComponent(c, key) + ext
+	}
+
+	hashed := strings.Join(components, "/")
+	if strings.HasPrefix(fp[len(volume):], "/") || strings.HasPrefix(fp[len(volume):], "\\") {
+		hashed = "/" + hashed
+	}
+
+	return volume + hashed
+}
+
+// hashComponent returns the first 16 hex characters of the HMAC-SHA256 of s with key.
+func hashComponent(s string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(s))
+
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
 // santizeMetaData sanitizes metadata (cursor position, dependencies, line number and lines).
 func santizeMetaData(h Heartbeat) Heartbeat {
 	h.CursorPosition = nil
@@ -72,15 +251,15 @@ func santizeMetaData(h Heartbeat) Heartbeat {
 	return h
 }
 
-// shouldSanitize checks a subject (entity, project, branch) of a heartbeat and
-// checks it against the passed in regex patterns to determine, if this heartbeat
-// should be sanitized.
-func shouldSanitize(subject string, patterns []*regexp.Regexp) bool {
+// matchPattern checks a subject (entity, project, branch) of a heartbeat
+// against the passed in regex patterns to determine, if this heartbeat should
+// be sanitized. Returns the first matching pattern.
+func matchPattern(subject string, patterns []matcher.Matcher) (matcher.Matcher, bool) {
 	for _, p := range patterns {
 		if p.MatchString(subject) {
-			return true
+			return p, true
 		}
 	}
 
-	return false
+	return nil, false
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,7 +13,7 @@ import (
 
 func TestWithSanitization_ObfuscateFile(t *testing.T) {
 	opt := heartbeat.WithSanitization(heartbeat.SanitizeConfig{
-		FilePatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		FilePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
@@ -48,7 +49,7 @@ func TestWithSanitization_ObfuscateFile(t *testing.T) {
 
 func TestSanitize_ObfuscateFile(t *testing.T) {
 	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
-		FilePatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		FilePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -65,8 +66,8 @@ func TestSanitize_ObfuscateFile(t *testing.T) {
 
 func TestSanitize_ObfuscateFile_SkipBranchIfNotMatching(t *testing.T) {
 	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
-		FilePatterns:   []*regexp.Regexp{regexp.MustCompile(".*")},
-		BranchPatterns: []*regexp.Regexp{regexp.MustCompile("not_matching")},
+		FilePatterns:   []matcher.Matcher{regexp.MustCompile(".*")},
+		BranchPatterns: []matcher.Matcher{regexp.MustCompile("not_matching")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -87,8 +88,8 @@ func TestSanitize_ObfuscateFile_NilFields(t *testing.T) {
 	h.Branch = nil
 
 	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
-		FilePatterns:   []*regexp.Regexp{regexp.MustCompile(".*")},
-		BranchPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		FilePatterns:   []matcher.Matcher{regexp.MustCompile(".*")},
+		BranchPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -105,7 +106,7 @@ func TestSanitize_ObfuscateFile_NilFields(t *testing.T) {
 
 func TestSanitize_ObfuscateProject(t *testing.T) {
 	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
-		ProjectPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		ProjectPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -120,10 +121,32 @@ func TestSanitize_ObfuscateProject(t *testing.T) {
 	}, r)
 }
 
+func TestSanitize_ObfuscateProject_ProjectHidden(t *testing.T) {
+	h := testHeartbeat()
+	h.Project = heartbeat.String("Empty Bonus 76")
+	h.ProjectHidden = true
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		ProjectPatterns: []matcher.Matcher{regexp.MustCompile("^wakatime$")},
+	})
+
+	assert.Equal(t, heartbeat.Heartbeat{
+		Category:      heartbeat.CodingCategory,
+		Entity:        "/tmp/main.go",
+		EntityType:    heartbeat.FileType,
+		IsWrite:       heartbeat.Bool(true),
+		Language:      heartbeat.String("golang"),
+		Project:       heartbeat.String("Empty Bonus 76"),
+		ProjectHidden: true,
+		Time:          1585598060,
+		UserAgent:     "wakatime/13.0.7",
+	}, r)
+}
+
 func TestSanitize_ObfuscateProject_SkipBranchIfNotMatching(t *testing.T) {
 	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
-		ProjectPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
-		BranchPatterns:  []*regexp.Regexp{regexp.MustCompile("not_matching")},
+		ProjectPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		BranchPatterns:  []matcher.Matcher{regexp.MustCompile("not_matching")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -144,8 +167,8 @@ func TestSanitize_ObfuscateProject_NilFields(t *testing.T) {
 	h.Branch = nil
 
 	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
-		ProjectPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
-		BranchPatterns:  []*regexp.Regexp{regexp.MustCompile(".*")},
+		ProjectPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		BranchPatterns:  []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -162,7 +185,7 @@ func TestSanitize_ObfuscateProject_NilFields(t *testing.T) {
 
 func TestSanitize_ObfuscateBranch(t *testing.T) {
 	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
-		BranchPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		BranchPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -187,7 +210,7 @@ func TestSanitize_ObfuscateBranch_NilFields(t *testing.T) {
 	h.Project = nil
 
 	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
-		BranchPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+		BranchPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 	})
 
 	assert.Equal(t, heartbeat.Heartbeat{
@@ -217,9 +240,9 @@ func TestSanitize_EntityTypeNotFile_DoesNothing(t *testing.T) {
 			h.EntityType = entityType
 
 			r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
-				BranchPatterns:  []*regexp.Regexp{regexp.MustCompile(".*")},
-				FilePatterns:    []*regexp.Regexp{regexp.MustCompile(".*")},
-				ProjectPatterns: []*regexp.Regexp{regexp.MustCompile(".*")},
+				BranchPatterns:  []matcher.Matcher{regexp.MustCompile(".*")},
+				FilePatterns:    []matcher.Matcher{regexp.MustCompile(".*")},
+				ProjectPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
 			})
 
 			assert.Equal(t, heartbeat.Heartbeat{
@@ -241,6 +264,146 @@ func TestSanitize_EntityTypeNotFile_DoesNothing(t *testing.T) {
 	}
 }
 
+func TestSanitize_GlobPatterns(t *testing.T) {
+	tests := map[string]struct {
+		Config         heartbeat.SanitizeConfig
+		ExpectedEntity string
+		ExpectedBranch *string
+	}{
+		"file glob matching": {
+			Config: heartbeat.SanitizeConfig{
+				FilePatterns:   []matcher.Matcher{matcher.MustCompile("glob:*.{go,py}")},
+				BranchPatterns: []matcher.Matcher{matcher.MustCompile("glob:release/*")},
+			},
+			ExpectedEntity: "HIDDEN.go",
+			ExpectedBranch: heartbeat.String("heartbeat"),
+		},
+		"file glob not matching": {
+			Config: heartbeat.SanitizeConfig{
+				FilePatterns: []matcher.Matcher{matcher.MustCompile("glob:/home/**/*.go")},
+			},
+			ExpectedEntity: "/tmp/main.go",
+			ExpectedBranch: heartbeat.String("heartbeat"),
+		},
+		"branch glob matching": {
+			Config: heartbeat.SanitizeConfig{
+				BranchPatterns: []matcher.Matcher{matcher.MustCompile("glob:heart*")},
+			},
+			ExpectedEntity: "/tmp/main.go",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := heartbeat.Sanitize(testHeartbeat(), test.Config)
+
+			assert.Equal(t, test.ExpectedEntity, r.Entity)
+			assert.Equal(t, test.ExpectedBranch, r.Branch)
+		})
+	}
+}
+
+func TestSanitize_ObfuscateFile_Hash(t *testing.T) {
+	r := heartbeat.Sanitize(testHeartbeat(), heartbeat.SanitizeConfig{
+		FilePatterns:    []matcher.Matcher{regexp.MustCompile(".*")},
+		FileNameHashKey: []byte("secret"),
+	})
+
+	assert.Equal(t, heartbeat.Heartbeat{
+		Category:   heartbeat.CodingCategory,
+		Entity:     heartbeat.HashFilePath("/tmp/main.go", []byte("secret")),
+		EntityType: heartbeat.FileType,
+		IsWrite:    heartbeat.Bool(true),
+		Language:   heartbeat.String("golang"),
+		Project:    heartbeat.String("wakatime"),
+		Time:       1585598060,
+		UserAgent:  "wakatime/13.0.7",
+	}, r)
+}
+
+func TestHashFilePath(t *testing.T) {
+	key := []byte("secret")
+
+	hashed := heartbeat.HashFilePath("/home/user/project/main.go", key)
+
+	assert.Regexp(t, "^/[0-9a-f]{16}/[0-9a-f]{16}/[0-9a-f]{16}/[0-9a-f]{16}\\.go$", hashed)
+	assert.Equal(t, hashed, heartbeat.HashFilePath("/home/user/project/main.go", key))
+	assert.NotEqual(t, hashed, heartbeat.HashFilePath("/home/user/project/main.go", []byte("other")))
+
+	// same directories map to the same components
+	other := heartbeat.HashFilePath("/home/user/project/lib.go", key)
+	assert.Equal(t, hashed[:len(hashed)-len("0123456789abcdef.go")], other[:len(other)-len("0123456789abcdef.go")])
+	assert.NotEqual(t, hashed, other)
+
+	assert.Regexp(t, "^[0-9a-f]{16}/[0-9a-f]{16}$", heartbeat.HashFilePath("src/Makefile", key))
+}
+
+func TestSanitize_HideProjectFolder(t *testing.T) {
+	tests := map[string]struct {
+		Entity           string
+		ProjectPath      string
+		Expected         string
+		ProjectRootCount *int
+	}{
+		"inside project folder": {
+			Entity:           "/home/alice/clients/acme/src/main.go",
+			ProjectPath:      "/home/alice/clients/acme",
+			Expected:         "src/main.go",
+			ProjectRootCount: heartbeat.Int(4),
+		},
+		"trailing slash": {
+			Entity:           "/home/alice/acme/main.go",
+			ProjectPath:      "/home/alice/acme/",
+			Expected:         "main.go",
+			ProjectRootCount: heartbeat.Int(3),
+		},
+		"outside project folder": {
+			Entity:      "/home/alice/other/main.go",
+			ProjectPath: "/home/alice/acme",
+			Expected:    "/home/alice/other/main.go",
+		},
+		"sibling with common prefix": {
+			Entity:      "/home/alice/acme-old/main.go",
+			ProjectPath: "/home/alice/acme",
+			Expected:    "/home/alice/acme-old/main.go",
+		},
+		"no project folder": {
+			Entity:   "/home/alice/acme/main.go",
+			Expected: "/home/alice/acme/main.go",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHeartbeat()
+			h.Entity = test.Entity
+			h.ProjectPath = test.ProjectPath
+
+			r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+				HideProjectFolder: true,
+			})
+
+			assert.Equal(t, test.Expected, r.Entity)
+			assert.Equal(t, test.ProjectRootCount, r.ProjectRootCount)
+		})
+	}
+}
+
+func TestSanitize_HideProjectFolder_Hash(t *testing.T) {
+	h := testHeartbeat()
+	h.Entity = "/home/alice/acme/src/main.go"
+	h.ProjectPath = "/home/alice/acme"
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		FilePatterns:      []matcher.Matcher{regexp.MustCompile(".*")},
+		FileNameHashKey:   []byte("secret"),
+		HideProjectFolder: true,
+	})
+
+	assert.Equal(t, heartbeat.HashFilePath("src/main.go", []byte("secret")), r.Entity)
+	assert.Equal(t, heartbeat.Int(3), r.ProjectRootCount)
+}
+
 func testHeartbeat() heartbeat.Heartbeat {
 	return heartbeat.Heartbeat{
 		Branch:         heartbeat.String("heartbeat"),
@@ -300,3 +463,15 @@ func TestSanitize_EmptyConfigDoNothing_EmptyDependencies(t *testing.T) {
 		UserAgent:      "wakatime/13.0.7",
 	}, r)
 }
+
+func TestSanitize_ObfuscateBranch_IssueKey(t *testing.T) {
+	h := testHeartbeat()
+	h.IssueKey = heartbeat.String("PROJ-1234")
+
+	r := heartbeat.Sanitize(h, heartbeat.SanitizeConfig{
+		BranchPatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+	})
+
+	assert.Nil(t, r.Branch)
+	assert.Nil(t, r.IssueKey)
+}
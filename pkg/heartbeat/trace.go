@@ -0,0 +1,46 @@
+package heartbeat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trace records the decisions of heartbeat handle options, explaining why a
+// heartbeat was dropped or modified. All methods are safe to call on a nil
+// Trace, which discards everything, so handle options can trace unconditionally.
+type Trace struct {
+	Entries []TraceEntry
+}
+
+// TraceEntry is a single decision of a handle option.
+type TraceEntry struct {
+	// Stage is the name of the handle option, like "filter" or "project".
+	Stage   string
+	Message string
+}
+
+// Add records a decision of stage. Arguments are handled in the manner of fmt.Printf.
+func (t *Trace) Add(stage, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+
+	t.Entries = append(t.Entries, TraceEntry{
+		Stage:   stage,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// String returns all recorded decisions, one per line.
+func (t *Trace) String() string {
+	if t == nil {
+		return ""
+	}
+
+	lines := make([]string, len(t.Entries))
+	for i, e := range t.Entries {
+		lines[i] = fmt.Sprintf("[%s] %s", e.Stage, e.Message)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,33 @@
+package heartbeat_test
+
+import (
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	trace := &heartbeat.Trace{}
+	trace.Add("filter", "skipping because matches exclude pattern %q", "^/tmp/")
+	trace.Add("project", "project %q set by %s", "wakatime", "git-detector")
+
+	assert.Equal(t, []heartbeat.TraceEntry{
+		{Stage: "filter", Message: `skipping because matches exclude pattern "^/tmp/"`},
+		{Stage: "project", Message: `project "wakatime" set by git-detector`},
+	}, trace.Entries)
+	assert.Equal(
+		t,
+		"[filter] skipping because matches exclude pattern \"^/tmp/\"\n[project] project \"wakatime\" set by git-detector",
+		trace.String(),
+	)
+}
+
+func TestTrace_Nil(t *testing.T) {
+	var trace *heartbeat.Trace
+
+	trace.Add("filter", "skipping")
+
+	assert.Equal(t, "", trace.String())
+}
@@ -0,0 +1,190 @@
+package heartbeat
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URLMode defines how much of a url entity is sent.
+type URLMode int
+
+const (
+	// URLModeSecrets keeps the url, but removes credentials and secret query parameters.
+	URLModeSecrets URLMode = iota
+	// URLModeQuery additionally removes query and fragment.
+	URLModeQuery
+	// URLModeHost strips the url to its host.
+	URLModeHost
+)
+
+const (
+	urlModeSecretsString = "secrets"
+	urlModeQueryString   = "query"
+	urlModeHostString    = "host"
+)
+
+// ParseURLMode parses a url mode from a string. An empty string is URLModeSecrets.
+func ParseURLMode(s string) (URLMode, error) {
+	switch strings.ToLower(s) {
+	case "", urlModeSecretsString:
+		return URLModeSecrets, nil
+	case urlModeQueryString:
+		return URLModeQuery, nil
+	case urlModeHostString:
+		return URLModeHost, nil
+	default:
+		return 0, fmt.Errorf("invalid url mode %q. must be one of secrets, query, host", s)
+	}
+}
+
+// String implements fmt.Stringer interface.
+func (m URLMode) String() string {
+	switch m {
+	case URLModeSecrets:
+		return urlModeSecretsString
+	case URLModeQuery:
+		return urlModeQueryString
+	case URLModeHost:
+		return urlModeHostString
+	default:
+		return ""
+	}
+}
+
+// DefaultSecretURLParams are query parameter names, which commonly contain
+// tokens, credentials or signatures. They are matched case insensitive.
+// nolint
+var DefaultSecretURLParams = []string{
+	"access_token",
+	"api_key",
+	"apikey",
+	"auth",
+	"client_secret",
+	"code",
+	"id_token",
+	"key",
+	"passwd",
+	"password",
+	"pwd",
+	"refresh_token",
+	"secret",
+	"session",
+	"session_id",
+	"sessionid",
+	"sid",
+	"sig",
+	"signature",
+	"token",
+	"x-amz-credential",
+	"x-amz-security-token",
+	"x-amz-signature",
+}
+
+// SanitizeURL sanitizes a domain or url entity following mode. Credentials
+// are always removed. Query parameters named like one of secretParams are
+// removed from query and fragment. Entities without scheme, like
+// "wakatime.com/dashboard", are returned without scheme.
+func SanitizeURL(entity string, mode URLMode, secretParams []string) string {
+	hasScheme := strings.Contains(entity, "://")
+
+	raw := entity
+	if !hasScheme {
+		raw = "http://" + entity
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		// not a url, so at least strip query and fragment
+		if i := strings.IndexAny(entity, "?#"); i >= 0 {
+			return entity[:i]
+		}
+
+		return entity
+	}
+
+	if mode == URLModeHost {
+		return u.Hostname()
+	}
+
+	u.User = nil
+
+	if mode == URLModeQuery {
+		u.RawQuery, u.Fragment, u.ForceQuery = "", "", false
+	} else {
+		u.RawQuery = removeSecretParams(u.RawQuery, secretParams)
+
+		// fragments like "#access_token=..." are used by oauth implicit flows
+		if strings.Contains(u.Fragment, "=") {
+			u.Fragment = removeSecretParams(u.Fragment, secretParams)
+		}
+	}
+
+	sanitized := u.String()
+	if !hasScheme {
+		sanitized = strings.TrimPrefix(sanitized, "http://")
+	}
+
+	return sanitized
+}
+
+// removeSecretParams removes parameters named like one of secretParams from
+// an url encoded query, keeping the order of all other parameters.
+func removeSecretParams(query string, secretParams []string) string {
+	if query == "" {
+		return ""
+	}
+
+	var kept []string
+
+	for _, param := range strings.Split(query, "&") {
+		name := strings.SplitN(param, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if isSecretParam(name, secretParams) {
+			continue
+		}
+
+		kept = append(kept, param)
+	}
+
+	return strings.Join(kept, "&")
+}
+
+// isSecretParam checks if name equals one of secretParams case insensitive.
+func isSecretParam(name string, secretParams []string) bool {
+	for _, p := range secretParams {
+		if strings.EqualFold(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sanitizeDomain hides a domain entity, if its host matches the domain
+// patterns. Otherwise sanitizes it following the url mode.
+func sanitizeDomain(h Heartbeat, config SanitizeConfig) Heartbeat {
+	host := SanitizeURL(h.Entity, URLModeHost, nil)
+
+	if p, ok := matchPattern(host, config.DomainPatterns); ok {
+		config.Trace.Add("sanitize", "domain matches hide_domain_names pattern %q. hiding domain", p.String())
+
+		h.Entity = "HIDDEN"
+
+		return h
+	}
+
+	secretParams := append(append([]string{}, DefaultSecretURLParams...), config.SecretURLParams...)
+
+	sanitized := SanitizeURL(h.Entity, config.URLMode, secretParams)
+	if sanitized != h.Entity {
+		config.Trace.Add("sanitize", "url sanitized following url mode %q", config.URLMode)
+	}
+
+	h.Entity = sanitized
+
+	return h
+}
@@ -0,0 +1,304 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GitCache evaluates git ignore rules without spawning git. Compiled patterns
+// are cached per repository and per directory, so it should be reused for
+// multiple paths. It is safe for concurrent use.
+type GitCache struct {
+	mu    sync.Mutex
+	repos map[string]*gitRepo
+}
+
+// gitRepo contains the ignore patterns of a single git repository.
+type gitRepo struct {
+	root string
+	// base contains patterns of core.excludesFile and .git/info/exclude,
+	// which have lower precedence than .gitignore files.
+	base Matcher
+	// dirs contains the patterns of .gitignore files per directory.
+	dirs map[string][]*Pattern
+}
+
+// NewGitCache creates a new GitCache.
+func NewGitCache() *GitCache {
+	return &GitCache{
+		repos: make(map[string]*gitRepo),
+	}
+}
+
+// Match returns the pattern deciding whether fp is ignored by git, or nil if
+// fp is not ignored or not located in a git repository. Patterns are read
+// from the global core.excludesFile, .git/info/exclude and all .gitignore
+// files from the repository root down to the directory of fp. Whether a file
+// is tracked despite matching an ignore pattern is not considered.
+func (c *GitCache) Match(fp string, isDir bool) (*Pattern, error) {
+	root, gitDir, ok := FindGitRepo(fp)
+	if !ok {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repo, ok := c.repos[root]
+	if !ok {
+		base, err := loadGitBase(root, gitDir)
+		if err != nil {
+			return nil, err
+		}
+
+		repo = &gitRepo{
+			root: root,
+			base: base,
+			dirs: make(map[string][]*Pattern),
+		}
+
+		c.repos[root] = repo
+	}
+
+	m, err := repo.matcher(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Match(fp, isDir), nil
+}
+
+// matcher returns all patterns applying to fp ordered by ascending precedence.
+func (r *gitRepo) matcher(fp string) (Matcher, error) {
+	var dirs []string
+
+	for dir := filepath.Dir(fp); isWithin(dir, r.root); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+
+		if dir == r.root {
+			break
+		}
+	}
+
+	m := append(Matcher{}, r.base...)
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		patterns, ok := r.dirs[dirs[i]]
+		if !ok {
+			var err error
+
+			patterns, err = parseFileIfExists(filepath.Join(dirs[i], ".gitignore"), dirs[i])
+			if err != nil {
+				return nil, err
+			}
+
+			r.dirs[dirs[i]] = patterns
+		}
+
+		m = append(m, patterns...)
+	}
+
+	return m, nil
+}
+
+// FindGitRepo searches the directory tree of fp for a git repository and
+// returns its working tree root and git directory. Worktrees and submodules
+// using a .git file are supported.
+func FindGitRepo(fp string) (root, gitDir string, ok bool) {
+	for dir := filepath.Dir(filepath.Clean(fp)); ; dir = filepath.Dir(dir) {
+		dotGit := filepath.Join(dir, ".git")
+
+		info, err := os.Stat(dotGit)
+		if err == nil {
+			if info.IsDir() {
+				return dir, dotGit, true
+			}
+
+			if gitDir, ok := readGitFile(dotGit); ok {
+				return dir, gitDir, true
+			}
+		}
+
+		if dir == filepath.Dir(dir) {
+			return "", "", false
+		}
+	}
+}
+
+// readGitFile reads the git directory from a .git file of the form
+// "gitdir: path".
+func readGitFile(fp string) (string, bool) {
+	data, err := ioutil.ReadFile(fp) // nolint:gosec
+	if err != nil {
+		return "", false
+	}
+
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", false
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(fp), gitDir)
+	}
+
+	return filepath.Clean(gitDir), true
+}
+
+// loadGitBase loads the patterns of the global core.excludesFile and of
+// .git/info/exclude, which are matched relative to the repository root.
+func loadGitBase(root, gitDir string) (Matcher, error) {
+	// worktrees share info/exclude and config with the main repository
+	commonDir := gitDir
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil { // nolint:gosec
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	var m Matcher
+
+	if fp := excludesFile(filepath.Join(commonDir, "config")); fp != "" {
+		patterns, err := parseFileIfExists(fp, root)
+		if err != nil {
+			return nil, err
+		}
+
+		m = append(m, patterns...)
+	}
+
+	patterns, err := parseFileIfExists(filepath.Join(commonDir, "info", "exclude"), root)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(m, patterns...), nil
+}
+
+// excludesFile returns the path of core.excludesFile. The global git config
+// files are read first and the repository config file at repoConfig last, so
+// that it takes precedence. Defaults to $XDG_CONFIG_HOME/git/ignore.
+func excludesFile(repoConfig string) string {
+	home, _ := os.UserHomeDir()
+
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" && home != "" {
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+
+	var fp string
+
+	if xdgConfigHome != "" {
+		fp = filepath.Join(xdgConfigHome, "git", "ignore")
+	}
+
+	configs := []string{repoConfig}
+
+	if home != "" {
+		configs = append([]string{filepath.Join(home, ".gitconfig")}, configs...)
+	}
+
+	if xdgConfigHome != "" {
+		configs = append([]string{filepath.Join(xdgConfigHome, "git", "config")}, configs...)
+	}
+
+	for _, config := range configs {
+		if value, ok := readGitConfigValue(config, "core", "excludesfile"); ok {
+			fp = value
+		}
+	}
+
+	if strings.HasPrefix(fp, "~/") && home != "" {
+		fp = filepath.Join(home, fp[2:])
+	}
+
+	return fp
+}
+
+// readGitConfigValue reads the last value of key in section from a git config
+// file. Section and key are matched case insensitive. Subsections, includes
+// and escape sequences other than quotes are not supported.
+func readGitConfigValue(fp, section, key string) (string, bool) {
+	f, err := os.Open(fp) // nolint:gosec
+	if err != nil {
+		return "", false
+	}
+
+	defer f.Close()
+
+	var (
+		current string
+		value   string
+		found   bool
+	)
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+
+			current = strings.ToLower(strings.TrimSpace(line[1:end]))
+			line = strings.TrimSpace(line[end+1:])
+
+			if line == "" {
+				continue
+			}
+		}
+
+		if current != section {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), key) {
+			continue
+		}
+
+		value, found = unquoteGitConfigValue(strings.TrimSpace(kv[1])), true
+	}
+
+	return value, found
+}
+
+// unquoteGitConfigValue removes quotes and trailing comments from a git config value.
+func unquoteGitConfigValue(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if end := strings.Index(s[1:], `"`); end >= 0 {
+			return s[1 : end+1]
+		}
+	}
+
+	if i := strings.IndexAny(s, "#;"); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// parseFileIfExists parses gitignore patterns from fp. A missing file results
+// in no patterns.
+func parseFileIfExists(fp, dir string) ([]*Pattern, error) {
+	patterns, err := ParseFile(fp, dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to parse %q: %s", fp, err)
+	}
+
+	return patterns, nil
+}
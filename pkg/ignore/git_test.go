@@ -0,0 +1,127 @@
+package ignore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/ignore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitCache_Match(t *testing.T) {
+	root, tearDown := setupTestGitRepo(t)
+	defer tearDown()
+
+	home, err := ioutil.TempDir(os.TempDir(), "wakatime-home")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(home)
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[user]\n\tname = test\n[core]\n\texcludesFile = ~/.gitignore_global\n")
+	// invalid patterns are skipped like in git
+	writeFile(t, filepath.Join(home, ".gitignore_global"), "*.swp\n[abc\n*.orig\n")
+	writeFile(t, filepath.Join(root, ".git", "info", "exclude"), "/local/\n!*.orig\n")
+	writeFile(t, filepath.Join(root, ".gitignore"), "/build/\nfoo\\\n*.gen.go\n")
+	writeFile(t, filepath.Join(root, "src", ".gitignore"), "!api.gen.go\n")
+
+	tests := map[string]struct {
+		Path     string
+		Expected bool
+	}{
+		"global excludes file": {
+			Path:     "src/main.go.swp",
+			Expected: true,
+		},
+		"info exclude overrides global excludes file": {
+			Path:     "src/main.go.orig",
+			Expected: false,
+		},
+		"info exclude": {
+			Path:     "local/notes.md",
+			Expected: true,
+		},
+		"root gitignore directory": {
+			Path:     "build/out/main",
+			Expected: true,
+		},
+		"anchored pattern does not match deeper directory": {
+			Path:     "src/build/main.go",
+			Expected: false,
+		},
+		"root gitignore pattern": {
+			Path:     "pkg/types.gen.go",
+			Expected: true,
+		},
+		"nested gitignore negates root pattern": {
+			Path:     "src/api.gen.go",
+			Expected: false,
+		},
+		"not ignored": {
+			Path:     "src/main.go",
+			Expected: false,
+		},
+	}
+
+	c := ignore.NewGitCache()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := c.Match(filepath.Join(root, test.Path), false)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, p != nil && !p.Negate)
+		})
+	}
+}
+
+func TestGitCache_Match_NoRepository(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	p, err := ignore.NewGitCache().Match(filepath.Join(tmpDir, "main.go"), false)
+	require.NoError(t, err)
+
+	assert.Nil(t, p)
+}
+
+func TestFindGitRepo_GitFile(t *testing.T) {
+	root, tearDown := setupTestGitRepo(t)
+	defer tearDown()
+
+	worktree := filepath.Join(root, "worktree")
+
+	err := os.MkdirAll(filepath.Join(worktree, "src"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, filepath.Join(worktree, ".git"), "gitdir: ../.git/worktrees/feature\n")
+
+	repo, gitDir, ok := ignore.FindGitRepo(filepath.Join(worktree, "src", "main.go"))
+	require.True(t, ok)
+
+	assert.Equal(t, worktree, repo)
+	assert.Equal(t, filepath.Join(root, ".git", "worktrees", "feature"), gitDir)
+}
+
+func setupTestGitRepo(t *testing.T) (string, func()) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime-git")
+	require.NoError(t, err)
+
+	for _, dir := range []string{".git/info", "src", "pkg", "build/out", "local"} {
+		err = os.MkdirAll(filepath.Join(root, dir), 0700)
+		require.NoError(t, err)
+	}
+
+	return root, func() { os.RemoveAll(root) }
+}
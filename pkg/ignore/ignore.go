@@ -0,0 +1,341 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Pattern is a single gitignore pattern.
+type Pattern struct {
+	// Source is the file the pattern was read from.
+	Source string
+	// Line is the line number of the pattern in its source file.
+	Line int
+	// Raw is the pattern as written in its source file.
+	Raw string
+	// Negate is set for patterns with a "!" prefix, which re-include paths.
+	Negate bool
+	// DirOnly is set for patterns with a trailing "/", which only match directories.
+	DirOnly bool
+
+	// dir is the directory, relative to which the pattern is matched.
+	dir   string
+	regex *regexp.Regexp
+}
+
+// String returns the pattern and its origin.
+func (p *Pattern) String() string {
+	if p.Source == "" {
+		return p.Raw
+	}
+
+	return fmt.Sprintf("%s:%d: %s", p.Source, p.Line, p.Raw)
+}
+
+// ParsePattern parses a single line of a gitignore file. The pattern is
+// matched against paths relative to dir. Returns false for blank lines and
+// comments.
+func ParsePattern(line, dir string) (*Pattern, bool, error) {
+	raw := line
+	line = trimTrailingSpaces(line)
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, false, nil
+	}
+
+	p := &Pattern{
+		Raw: raw,
+		dir: filepath.Clean(dir),
+	}
+
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.Negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	if line == "" {
+		return nil, false, nil
+	}
+
+	// a separator at the beginning or in the middle anchors the pattern to dir
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := toRegex(line)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid pattern %q: %s", raw, err)
+	}
+
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	p.regex, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid pattern %q: %s", raw, err)
+	}
+
+	return p, true, nil
+}
+
+// Parse parses gitignore patterns from r, which are matched against paths
+// relative to dir. Source is used to reference the origin of patterns. Like
+// git, invalid patterns are skipped.
+func Parse(r io.Reader, source, dir string) ([]*Pattern, error) {
+	var patterns []*Pattern
+
+	scanner := bufio.NewScanner(r)
+
+	var n int
+
+	for scanner.Scan() {
+		n++
+
+		p, ok, err := ParsePattern(scanner.Text(), dir)
+		if err != nil {
+			jww.WARN.Printf("skipping pattern at %s:%d: %s", source, n, err)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		p.Source = source
+		p.Line = n
+
+		patterns = append(patterns, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading %q: %s", source, err)
+	}
+
+	return patterns, nil
+}
+
+// ParseFile parses gitignore patterns from the file at fp. Patterns are
+// matched against paths relative to dir.
+func ParseFile(fp, dir string) ([]*Pattern, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Parse(f, fp, dir)
+}
+
+// Matcher matches paths against a list of patterns with gitignore semantics.
+// Patterns are ordered by ascending precedence, so the last matching pattern
+// decides whether a path is ignored.
+type Matcher []*Pattern
+
+// LoadHierarchy reads all files with the given name in the directories from
+// root down to the directory of fp. Patterns of deeper files take precedence.
+// Root must be an ancestor of fp, otherwise no files are read.
+func LoadHierarchy(name, fp, root string) (Matcher, error) {
+	root = filepath.Clean(root)
+
+	var dirs []string
+
+	for dir := filepath.Dir(fp); ; dir = filepath.Dir(dir) {
+		if !isWithin(dir, root) {
+			return nil, nil
+		}
+
+		dirs = append(dirs, dir)
+
+		if dir == root {
+			break
+		}
+	}
+
+	var m Matcher
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		patterns, err := parseFileIfExists(filepath.Join(dirs[i], name), dirs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		m = append(m, patterns...)
+	}
+
+	return m, nil
+}
+
+// Match returns the pattern deciding whether fp is ignored, or nil if no
+// pattern matches. Like git, a path inside an ignored directory cannot be
+// re-included.
+func (m Matcher) Match(fp string, isDir bool) *Pattern {
+	if len(m) == 0 {
+		return nil
+	}
+
+	fp = filepath.Clean(fp)
+
+	var parents []string
+	for dir := filepath.Dir(fp); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		if p := m.match(parents[i], true); p != nil && !p.Negate {
+			return p
+		}
+	}
+
+	return m.match(fp, isDir)
+}
+
+// Ignored checks if fp is ignored.
+func (m Matcher) Ignored(fp string, isDir bool) bool {
+	p := m.Match(fp, isDir)
+	return p != nil && !p.Negate
+}
+
+// match returns the last pattern matching fp without considering parent directories.
+func (m Matcher) match(fp string, isDir bool) *Pattern {
+	for i := len(m) - 1; i >= 0; i-- {
+		p := m[i]
+
+		if p.DirOnly && !isDir {
+			continue
+		}
+
+		if fp == p.dir || !isWithin(fp, p.dir) {
+			continue
+		}
+
+		rel, err := filepath.Rel(p.dir, fp)
+		if err != nil {
+			continue
+		}
+
+		if p.regex.MatchString(filepath.ToSlash(rel)) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// isWithin checks if fp equals dir or is located below dir.
+func isWithin(fp, dir string) bool {
+	if fp == dir {
+		return true
+	}
+
+	return strings.HasPrefix(fp, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// toRegex converts a gitignore pattern without anchoring and trailing slash
+// into a regular expression.
+func toRegex(pattern string) (string, error) {
+	var b strings.Builder
+
+	segments := strings.Split(pattern, "/")
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		if segment == "**" {
+			switch {
+			case len(segments) == 1:
+				b.WriteString(".*")
+			case last:
+				// trailing "/**" matches everything inside
+				b.WriteString(".*")
+			default:
+				// leading "**/" and inner "/**/" match zero or more directories
+				b.WriteString("(?:.*/)?")
+			}
+
+			continue
+		}
+
+		if err := writeSegment(&b, segment); err != nil {
+			return "", err
+		}
+
+		if !last {
+			b.WriteString("/")
+		}
+	}
+
+	return b.String(), nil
+}
+
+// writeSegment writes the regular expression for a single path segment.
+func writeSegment(b *strings.Builder, segment string) error {
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+
+		switch c {
+		case '\\':
+			if i+1 >= len(segment) {
+				return fmt.Errorf("trailing escape character")
+			}
+
+			i++
+			b.WriteString(regexp.QuoteMeta(string(segment[i])))
+		case '*':
+			b.WriteString("[^/]*")
+
+			// other consecutive asterisks are regular asterisks
+			for i+1 < len(segment) && segment[i+1] == '*' {
+				i++
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(segment[i+1:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated character class")
+			}
+
+			class := segment[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return nil
+}
+
+// trimTrailingSpaces removes trailing spaces, unless they are escaped.
+func trimTrailingSpaces(s string) string {
+	s = strings.TrimRight(s, "\r")
+
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+
+	return s
+}
@@ -0,0 +1,242 @@
+package ignore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/ignore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher_Ignored(t *testing.T) {
+	tests := map[string]struct {
+		Patterns string
+		Path     string
+		IsDir    bool
+		Expected bool
+	}{
+		"basename at any level": {
+			Patterns: "*.log",
+			Path:     "/repo/src/debug.log",
+			Expected: true,
+		},
+		"no match": {
+			Patterns: "*.log",
+			Path:     "/repo/src/main.go",
+			Expected: false,
+		},
+		"comment and blank lines": {
+			Patterns: "# *.go\n\n",
+			Path:     "/repo/main.go",
+			Expected: false,
+		},
+		"escaped hash": {
+			Patterns: `\#notes`,
+			Path:     "/repo/#notes",
+			Expected: true,
+		},
+		"negation": {
+			Patterns: "*.log\n!keep.log",
+			Path:     "/repo/keep.log",
+			Expected: false,
+		},
+		"negation overridden by later pattern": {
+			Patterns: "!keep.log\n*.log",
+			Path:     "/repo/keep.log",
+			Expected: true,
+		},
+		"escaped exclamation mark": {
+			Patterns: `\!important`,
+			Path:     "/repo/!important",
+			Expected: true,
+		},
+		"directory only matches directory": {
+			Patterns: "build/",
+			Path:     "/repo/build",
+			IsDir:    true,
+			Expected: true,
+		},
+		"directory only does not match file": {
+			Patterns: "build/",
+			Path:     "/repo/build",
+			Expected: false,
+		},
+		"directory only matches files inside": {
+			Patterns: "build/",
+			Path:     "/repo/src/build/out.js",
+			Expected: true,
+		},
+		"file inside ignored directory cannot be re-included": {
+			Patterns: "build/\n!build/keep.js",
+			Path:     "/repo/build/keep.js",
+			Expected: true,
+		},
+		"re-include file with wildcard exclude": {
+			Patterns: "build/*\n!build/keep.js",
+			Path:     "/repo/build/keep.js",
+			Expected: false,
+		},
+		"leading slash anchors": {
+			Patterns: "/main.go",
+			Path:     "/repo/src/main.go",
+			Expected: false,
+		},
+		"leading slash anchored match": {
+			Patterns: "/main.go",
+			Path:     "/repo/main.go",
+			Expected: true,
+		},
+		"middle slash anchors": {
+			Patterns: "src/*.go",
+			Path:     "/repo/pkg/src/main.go",
+			Expected: false,
+		},
+		"star does not match separator": {
+			Patterns: "src/*.go",
+			Path:     "/repo/src/pkg/main.go",
+			Expected: false,
+		},
+		"leading double star": {
+			Patterns: "**/fixtures",
+			Path:     "/repo/a/b/fixtures/data.json",
+			Expected: true,
+		},
+		"trailing double star": {
+			Patterns: "vendor/**",
+			Path:     "/repo/vendor/github.com/lib/lib.go",
+			Expected: true,
+		},
+		"trailing double star does not match directory itself": {
+			Patterns: "vendor/**",
+			Path:     "/repo/vendor",
+			IsDir:    true,
+			Expected: false,
+		},
+		"inner double star matches zero directories": {
+			Patterns: "docs/**/*.md",
+			Path:     "/repo/docs/index.md",
+			Expected: true,
+		},
+		"inner double star matches multiple directories": {
+			Patterns: "docs/**/*.md",
+			Path:     "/repo/docs/a/b/index.md",
+			Expected: true,
+		},
+		"question mark and class": {
+			Patterns: "file?.[ch]",
+			Path:     "/repo/file1.c",
+			Expected: true,
+		},
+		"negated class": {
+			Patterns: "file[!0-9].c",
+			Path:     "/repo/file1.c",
+			Expected: false,
+		},
+		"trailing spaces are trimmed": {
+			Patterns: "main.go   ",
+			Path:     "/repo/main.go",
+			Expected: true,
+		},
+		"path outside of pattern dir": {
+			Patterns: "*.go",
+			Path:     "/other/main.go",
+			Expected: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patterns, err := ignore.Parse(strings.NewReader(test.Patterns), ".wakatimeignore", "/repo")
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, ignore.Matcher(patterns).Ignored(test.Path, test.IsDir))
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	patterns, err := ignore.Parse(strings.NewReader("*.go\n[abc\nfoo\\\n*.log"), ".wakatimeignore", "/repo")
+	require.NoError(t, err)
+
+	require.Len(t, patterns, 2)
+
+	assert.Equal(t, ".wakatimeignore:1: *.go", patterns[0].String())
+	assert.Equal(t, ".wakatimeignore:4: *.log", patterns[1].String())
+}
+
+func TestMatcher_Match_Pattern(t *testing.T) {
+	patterns, err := ignore.Parse(strings.NewReader("*.log\n!keep.log"), ".wakatimeignore", "/repo")
+	require.NoError(t, err)
+
+	p := ignore.Matcher(patterns).Match("/repo/keep.log", false)
+	require.NotNil(t, p)
+
+	assert.True(t, p.Negate)
+	assert.Equal(t, 2, p.Line)
+	assert.Equal(t, ".wakatimeignore:2: !keep.log", p.String())
+}
+
+func TestLoadHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "wakatime-ignore")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	err = os.MkdirAll(filepath.Join(root, "src", "generated"), 0700)
+	require.NoError(t, err)
+
+	writeFile(t, filepath.Join(root, ".wakatimeignore"), "*.gen.go\nsecrets/\n")
+	writeFile(t, filepath.Join(root, "src", "generated", ".wakatimeignore"), "!*.gen.go\n/local.go\n")
+
+	tests := map[string]struct {
+		Path     string
+		Expected bool
+	}{
+		"root pattern": {
+			Path:     filepath.Join(root, "src", "api.gen.go"),
+			Expected: true,
+		},
+		"deeper file negates root pattern": {
+			Path:     filepath.Join(root, "src", "generated", "api.gen.go"),
+			Expected: false,
+		},
+		"anchored to deeper file": {
+			Path:     filepath.Join(root, "src", "generated", "local.go"),
+			Expected: true,
+		},
+		"anchored to deeper file no match": {
+			Path:     filepath.Join(root, "src", "local.go"),
+			Expected: false,
+		},
+		"directory pattern": {
+			Path:     filepath.Join(root, "src", "secrets", "key.go"),
+			Expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := ignore.LoadHierarchy(".wakatimeignore", test.Path, root)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, m.Ignored(test.Path, false))
+		})
+	}
+}
+
+func TestLoadHierarchy_OutsideRoot(t *testing.T) {
+	m, err := ignore.LoadHierarchy(".wakatimeignore", "/other/main.go", "/repo")
+	require.NoError(t, err)
+
+	assert.Nil(t, m)
+}
+
+func writeFile(t *testing.T, fp, data string) {
+	err := ioutil.WriteFile(fp, []byte(data), 0600)
+	require.NoError(t, err)
+}
@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/glob"
+)
+
+const (
+	// GlobPrefix marks a pattern as glob pattern.
+	GlobPrefix = "glob:"
+	// RegexPrefix marks a pattern explicitly as regular expression. Patterns
+	// without prefix are regular expressions, too.
+	RegexPrefix = "re:"
+)
+
+// Matcher matches strings like file paths, project or branch names against
+// a pattern. *regexp.Regexp implements Matcher.
+type Matcher interface {
+	// MatchString reports whether s matches the pattern.
+	MatchString(s string) bool
+	// String returns the source of the pattern.
+	String() string
+}
+
+// Compile parses a pattern into a Matcher. Patterns prefixed with "glob:" are
+// glob patterns supporting "**" and brace expansion, see glob.Compile. A glob
+// pattern without path separator matches in any directory, so "glob:*.log"
+// matches "/var/log/app.log". All other patterns are regular expressions,
+// optionally prefixed with "re:".
+func Compile(pattern string) (Matcher, error) {
+	switch {
+	case strings.HasPrefix(pattern, GlobPrefix):
+		return compileGlob(strings.TrimPrefix(pattern, GlobPrefix))
+	case strings.HasPrefix(pattern, RegexPrefix):
+		return compileRegex(strings.TrimPrefix(pattern, RegexPrefix))
+	default:
+		return compileRegex(pattern)
+	}
+}
+
+// MustCompile is like Compile, but panics if the pattern cannot be parsed.
+func MustCompile(pattern string) Matcher {
+	m, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+func compileRegex(pattern string) (Matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex %q: %s", pattern, err)
+	}
+
+	return re, nil
+}
+
+// Glob is a Matcher for glob patterns.
+type Glob struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func compileGlob(pattern string) (Matcher, error) {
+	expr := pattern
+	if !strings.Contains(expr, "/") {
+		expr = "**/" + expr
+	}
+
+	re, err := glob.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile glob %q: %s", pattern, err)
+	}
+
+	return Glob{
+		pattern: pattern,
+		regex:   re,
+	}, nil
+}
+
+// MatchString reports whether s matches the glob pattern.
+func (g Glob) MatchString(s string) bool {
+	return g.regex.MatchString(s)
+}
+
+// String returns the glob pattern including the "glob:" prefix.
+func (g Glob) String() string {
+	return GlobPrefix + g.pattern
+}
@@ -0,0 +1,107 @@
+package matcher_test
+
+import (
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := map[string]struct {
+		Pattern  string
+		Subject  string
+		Expected bool
+	}{
+		"regex": {
+			Pattern:  "^/tmp/.*\\.log$",
+			Subject:  "/tmp/app.log",
+			Expected: true,
+		},
+		"explicit regex": {
+			Pattern:  "re:\\.log$",
+			Subject:  "/var/log/app.log",
+			Expected: true,
+		},
+		"glob extension in any directory": {
+			Pattern:  "glob:*.log",
+			Subject:  "/var/log/app.log",
+			Expected: true,
+		},
+		"glob extension without directory": {
+			Pattern:  "glob:*.log",
+			Subject:  "app.log",
+			Expected: true,
+		},
+		"glob extension mismatch": {
+			Pattern: "glob:*.log",
+			Subject: "/var/log/app.logger",
+		},
+		"glob double star": {
+			Pattern:  "glob:/home/**/node_modules/",
+			Subject:  "/home/user/src/app/node_modules/react/index.js",
+			Expected: true,
+		},
+		"glob single star does not cross directories": {
+			Pattern: "glob:/home/*/main.go",
+			Subject: "/home/user/src/main.go",
+		},
+		"glob matches everything below directory": {
+			Pattern:  "glob:/home/*/secret",
+			Subject:  "/home/user/secret/keys.txt",
+			Expected: true,
+		},
+		"glob brace expansion": {
+			Pattern:  "glob:**/*.{png,jpg}",
+			Subject:  "/home/user/img/logo.jpg",
+			Expected: true,
+		},
+		"glob brace expansion mismatch": {
+			Pattern: "glob:**/*.{png,jpg}",
+			Subject: "/home/user/img/logo.gif",
+		},
+		"glob project name": {
+			Pattern:  "glob:client-*",
+			Subject:  "client-acme",
+			Expected: true,
+		},
+		"glob branch name": {
+			Pattern:  "glob:feature/*",
+			Subject:  "feature/login",
+			Expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := matcher.Compile(test.Pattern)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Expected, m.MatchString(test.Subject))
+		})
+	}
+}
+
+func TestCompile_String(t *testing.T) {
+	assert.Equal(t, "glob:*.log", matcher.MustCompile("glob:*.log").String())
+	assert.Equal(t, "\\.log$", matcher.MustCompile("re:\\.log$").String())
+	assert.Equal(t, "\\.log$", matcher.MustCompile("\\.log$").String())
+}
+
+func TestCompile_Err(t *testing.T) {
+	tests := map[string]string{
+		"invalid regex":          "*.log",
+		"invalid explicit regex": "re:[",
+		"unterminated brace":     "glob:*.{png,jpg",
+		"unterminated class":     "glob:[abc",
+	}
+
+	for name, pattern := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := matcher.Compile(pattern)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package offline
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// encryptedPrefix marks encrypted heartbeat data and its format version.
+	encryptedPrefix = "enc:v1:"
+	// keySize is the size of the AES-256 key.
+	keySize = 32
+	// apiKeyContext separates keys derived from the api key from other uses of it.
+	apiKeyContext = "wakatime-cli offline queue encryption v1"
+)
+
+// ErrDecrypt is returned, if queued heartbeat data cannot be decrypted, e.g.
+// because the key changed or the data was tampered with.
+var ErrDecrypt = errors.New("failed to decrypt queued heartbeat data")
+
+// Cipher encrypts and authenticates queued heartbeat data with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a new Cipher. The key must be 32 bytes long.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key size %d. must be %d bytes", len(key), keySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block cipher: %s", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm cipher: %s", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts data and returns it base64 encoded with a version prefix.
+// The heartbeat id is authenticated as additional data, so that encrypted
+// data cannot be swapped between rows.
+func (c *Cipher) Encrypt(id string, data []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %s", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, data, []byte(id))
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt. Returns ErrDecrypt on failure.
+func (c *Cipher) Decrypt(id string, data string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(data, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// isEncrypted checks if queued heartbeat data is encrypted.
+func isEncrypted(data string) bool {
+	return strings.HasPrefix(data, encryptedPrefix)
+}
+
+// KeyFromAPIKey derives an encryption key from the api key.
+func KeyFromAPIKey(apiKey string) []byte {
+	sum := sha256.Sum256([]byte(apiKeyContext + "\x00" + apiKey))
+
+	return sum[:]
+}
+
+// LoadKeyFile reads a base64 encoded key from the file at fp. A missing key
+// file is created with a random key, readable only by the current user.
+func LoadKeyFile(fp string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fp) // nolint:gosec
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read key file: %s", err)
+	}
+
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key file: %s", err)
+		}
+
+		if len(key) != keySize {
+			return nil, fmt.Errorf("invalid key size %d in key file. must be %d bytes", len(key), keySize)
+		}
+
+		return key, nil
+	}
+
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %s", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+
+	if err := ioutil.WriteFile(fp, []byte(encoded), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %s", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,135 @@
+package offline_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/offline"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := offline.NewCipher(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("id", []byte(`{"entity":"/tmp/main.go"}`))
+	require.NoError(t, err)
+
+	assert.NotContains(t, encrypted, "main.go")
+
+	decrypted, err := c.Decrypt("id", encrypted)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"entity":"/tmp/main.go"}`, string(decrypted))
+}
+
+func TestCipher_ErrDecrypt(t *testing.T) {
+	c, err := offline.NewCipher(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+
+	other, err := offline.NewCipher(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("id", []byte("data"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		Cipher *offline.Cipher
+		ID     string
+		Data   string
+	}{
+		"wrong key": {
+			Cipher: other,
+			ID:     "id",
+			Data:   encrypted,
+		},
+		"wrong id": {
+			Cipher: c,
+			ID:     "other",
+			Data:   encrypted,
+		},
+		"tampered": {
+			Cipher: c,
+			ID:     "id",
+			Data:   encrypted[:len(encrypted)-4] + "AAA=",
+		},
+		"invalid base64": {
+			Cipher: c,
+			ID:     "id",
+			Data:   "enc:v1:???",
+		},
+		"too short": {
+			Cipher: c,
+			ID:     "id",
+			Data:   "enc:v1:AAAA",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := test.Cipher.Decrypt(test.ID, test.Data)
+
+			assert.True(t, errors.Is(err, offline.ErrDecrypt))
+		})
+	}
+}
+
+func TestNewCipher_InvalidKeySize(t *testing.T) {
+	_, err := offline.NewCipher([]byte("short"))
+
+	assert.EqualError(t, err, "invalid key size 5. must be 32 bytes")
+}
+
+func TestKeyFromAPIKey(t *testing.T) {
+	key := offline.KeyFromAPIKey("00000000-0000-4000-8000-000000000000")
+
+	assert.Len(t, key, 32)
+	assert.Equal(t, key, offline.KeyFromAPIKey("00000000-0000-4000-8000-000000000000"))
+	assert.NotEqual(t, key, offline.KeyFromAPIKey("00000000-0000-4000-8000-000000000001"))
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime-offline.key")
+
+	key, err := offline.LoadKeyFile(fp)
+	require.NoError(t, err)
+
+	assert.Len(t, key, 32)
+
+	info, err := os.Stat(fp)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := offline.LoadKeyFile(fp)
+	require.NoError(t, err)
+
+	assert.Equal(t, key, loaded)
+}
+
+func TestLoadKeyFile_InvalidKey(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime-offline.key")
+
+	err = ioutil.WriteFile(fp, []byte("c2hvcnQ=\n"), 0600)
+	require.NoError(t, err)
+
+	_, err = offline.LoadKeyFile(fp)
+
+	assert.EqualError(t, err, "invalid key size 5 in key file. must be 32 bytes")
+}
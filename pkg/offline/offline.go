@@ -3,6 +3,7 @@ package offline
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -21,7 +22,7 @@ const (
 // failing connection to API, failed sending or errors returned by API, the
 // heartbeats will be temporarily stored in a sqlite DB and sending will be
 // retried at next usages of the wakatime cli.
-func WithQueue(filepath string, syncLimit int) (heartbeat.HandleOption, error) {
+func WithQueue(filepath string, syncLimit int, opts ...Option) (heartbeat.HandleOption, error) {
 	conn, err := sql.Open("sqlite3", filepath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open db connection: %s", err)
@@ -32,6 +33,10 @@ func WithQueue(filepath string, syncLimit int) (heartbeat.HandleOption, error) {
 		return nil, fmt.Errorf("failed to initialize db: %s", err)
 	}
 
+	if err := migrate(conn, opts...); err != nil {
+		return nil, err
+	}
+
 	return func(next heartbeat.Handle) heartbeat.Handle {
 		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 			// start transaction
@@ -43,7 +48,7 @@ func WithQueue(filepath string, syncLimit int) (heartbeat.HandleOption, error) {
 			// nolint
 			defer tx.Rollback()
 
-			queue := NewQueue(tx)
+			queue := NewQueue(tx, opts...)
 
 			queued, err := queue.PopMany(syncLimit)
 			if err != nil {
@@ -105,14 +110,115 @@ type DB interface {
 
 // Queue is a queue to temporarily store heartbeats.
 type Queue struct {
-	conn DB
+	conn   DB
+	cipher *Cipher
+}
+
+// Option is a functional option for Queue.
+type Option func(*Queue)
+
+// WithEncryption sets a cipher to encrypt queued heartbeat data with. Queued
+// data, which cannot be decrypted, is neither returned nor deleted.
+func WithEncryption(c *Cipher) Option {
+	return func(q *Queue) {
+		q.cipher = c
+	}
 }
 
 // NewQueue creates a new Queue instance.
-func NewQueue(conn DB) *Queue {
-	return &Queue{
+func NewQueue(conn DB, opts ...Option) *Queue {
+	q := &Queue{
 		conn: conn,
 	}
+
+	for _, option := range opts {
+		option(q)
+	}
+
+	return q
+}
+
+// migrate encrypts plaintext heartbeat data queued before encryption was enabled.
+func migrate(conn *sql.DB, opts ...Option) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start offline queue db transaction: %s", err)
+	}
+	// nolint
+	defer tx.Rollback()
+
+	n, err := NewQueue(tx, opts...).EncryptAll()
+	if err != nil {
+		return fmt.Errorf("failed to encrypt offline queue: %s", err)
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit offline queue db transaction: %s", err)
+	}
+
+	jww.DEBUG.Printf("encrypted %d plaintext heartbeat(s) in offline queue", n)
+
+	return nil
+}
+
+// EncryptAll encrypts all plaintext heartbeat data in the queue and returns
+// the number of encrypted rows. Does nothing, if no cipher is set.
+func (q *Queue) EncryptAll() (int, error) {
+	if q.cipher == nil {
+		return 0, nil
+	}
+
+	rows, err := q.conn.Query(
+		fmt.Sprintf("SELECT rowid, id, heartbeat FROM %s WHERE heartbeat NOT LIKE $1;", tableName),
+		encryptedPrefix+"%",
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute select db query: %s", err)
+	}
+
+	defer rows.Close()
+
+	type row struct {
+		RowID int64
+		Data  string
+	}
+
+	var plaintext []row
+
+	for rows.Next() {
+		var (
+			r  row
+			id string
+		)
+
+		if err := rows.Scan(&r.RowID, &id, &r.Data); err != nil {
+			return 0, fmt.Errorf("failed to scan row: %s", err)
+		}
+
+		r.Data, err = q.cipher.Encrypt(id, []byte(r.Data))
+		if err != nil {
+			return 0, err
+		}
+
+		plaintext = append(plaintext, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("row error: %s", err)
+	}
+
+	for _, r := range plaintext {
+		_, err = q.conn.Exec(fmt.Sprintf("UPDATE %s SET heartbeat = $1 WHERE rowid = $2", tableName), r.Data, r.RowID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to execute update db query: %s", err)
+		}
+	}
+
+	return len(plaintext), nil
 }
 
 // PushMany adds multiple heartbeats to the queue.
@@ -129,7 +235,16 @@ func (q *Queue) PushMany(hh []heartbeat.Heartbeat) error {
 			return fmt.Errorf("failed to json encode heartbeat: %s", err)
 		}
 
-		result, err := stmt.Exec(h.ID(), data)
+		var value interface{} = data
+
+		if q.cipher != nil {
+			value, err = q.cipher.Encrypt(h.ID(), data)
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := stmt.Exec(h.ID(), value)
 		if err != nil {
 			return fmt.Errorf("failed to execute db query: %s", err)
 		}
@@ -147,25 +262,32 @@ func (q *Queue) PushMany(hh []heartbeat.Heartbeat) error {
 	return nil
 }
 
-// PopMany takes multiple heartbeats from the queue.
+// PopMany takes up to limit heartbeats from the queue. Heartbeats, which
+// cannot be decrypted, are skipped and kept in the queue, so that they do not
+// block the heartbeats queued after them.
 func (q *Queue) PopMany(limit int) ([]heartbeat.Heartbeat, error) {
-	rows, err := q.conn.Query(fmt.Sprintf("SELECT id, heartbeat FROM %s LIMIT $1;", tableName), limit)
+	rows, err := q.conn.Query(fmt.Sprintf("SELECT rowid, id, heartbeat FROM %s;", tableName))
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute select db query: %s", err)
 	}
 
+	defer rows.Close()
+
 	var (
-		ids        []string
+		rowIDs     []int64
 		heartbeats []heartbeat.Heartbeat
+		skipped    int
 	)
 
-	for rows.Next() {
+	for len(heartbeats) < limit && rows.Next() {
 		var (
-			id   string
-			data string
+			rowID int64
+			id    string
+			data  string
 		)
 
 		err := rows.Scan(
+			&rowID,
 			&id,
 			&data,
 		)
@@ -173,15 +295,24 @@ func (q *Queue) PopMany(limit int) ([]heartbeat.Heartbeat, error) {
 			return nil, fmt.Errorf("failed to scan row: %s", err)
 		}
 
-		ids = append(ids, id)
+		decrypted, err := q.decrypt(id, data)
+		if errors.Is(err, ErrDecrypt) {
+			skipped++
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
 
 		var h heartbeat.Heartbeat
 
-		err = json.Unmarshal([]byte(data), &h)
+		err = json.Unmarshal(decrypted, &h)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse heartbeat json data: %s", err)
 		}
 
+		rowIDs = append(rowIDs, rowID)
 		heartbeats = append(heartbeats, h)
 	}
 
@@ -189,8 +320,15 @@ func (q *Queue) PopMany(limit int) ([]heartbeat.Heartbeat, error) {
 		return nil, fmt.Errorf("row error: %s", err)
 	}
 
-	for _, id := range ids {
-		_, err = q.conn.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", tableName), id)
+	if skipped > 0 {
+		jww.WARN.Printf("skipping %d heartbeat(s) in offline queue: %s", skipped, ErrDecrypt)
+	}
+
+	// close before deleting, as rows are not read to the end, if limit is reached
+	rows.Close()
+
+	for _, rowID := range rowIDs {
+		_, err = q.conn.Exec(fmt.Sprintf("DELETE FROM %s WHERE rowid = $1", tableName), rowID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute delete db query: %s", err)
 		}
@@ -198,3 +336,17 @@ func (q *Queue) PopMany(limit int) ([]heartbeat.Heartbeat, error) {
 
 	return heartbeats, nil
 }
+
+// decrypt returns the decrypted heartbeat data. Plaintext data is returned as
+// is. Returns ErrDecrypt, if data is encrypted, but cannot be decrypted.
+func (q *Queue) decrypt(id string, data string) ([]byte, error) {
+	if !isEncrypted(data) {
+		return []byte(data), nil
+	}
+
+	if q.cipher == nil {
+		return nil, fmt.Errorf("%w: no encryption key configured", ErrDecrypt)
+	}
+
+	return q.cipher.Decrypt(id, data)
+}
@@ -1,12 +1,14 @@
 package offline_test
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -539,6 +541,198 @@ func TestQueue_PopMany_Limit(t *testing.T) {
 	}, heartbeats)
 }
 
+func TestQueue_Encryption(t *testing.T) {
+	conn, cleanup := initDB(t)
+	defer cleanup()
+
+	c := testCipher(t, 1)
+
+	q := offline.NewQueue(conn, offline.WithEncryption(c))
+	err := q.PushMany(testHeartbeats())
+	require.NoError(t, err)
+
+	for _, data := range selectHeartbeatData(t, conn) {
+		assert.True(t, strings.HasPrefix(data, "enc:v1:"))
+		assert.NotContains(t, data, "main.go")
+	}
+
+	hh, err := q.PopMany(10)
+	require.NoError(t, err)
+
+	assert.Equal(t, testHeartbeats(), hh)
+	assert.Empty(t, selectHeartbeatData(t, conn))
+}
+
+func TestQueue_Encryption_ErrDecrypt(t *testing.T) {
+	tests := map[string][]offline.Option{
+		"wrong key": {offline.WithEncryption(testCipher(t, 2))},
+		"no key":    nil,
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn, cleanup := initDB(t)
+			defer cleanup()
+
+			err := offline.NewQueue(conn, offline.WithEncryption(testCipher(t, 1))).PushMany(testHeartbeats())
+			require.NoError(t, err)
+
+			hh, err := offline.NewQueue(conn, opts...).PopMany(10)
+			require.NoError(t, err)
+
+			assert.Empty(t, hh)
+
+			// fail closed: nothing is deleted
+			assert.Len(t, selectHeartbeatData(t, conn), 2)
+		})
+	}
+}
+
+func TestQueue_Encryption_ErrDecrypt_SkipsRows(t *testing.T) {
+	conn, cleanup := initDB(t)
+	defer cleanup()
+
+	heartbeats := testHeartbeats()
+
+	// the undecryptable row is queued first
+	err := offline.NewQueue(conn, offline.WithEncryption(testCipher(t, 1))).PushMany(heartbeats[:1])
+	require.NoError(t, err)
+
+	q := offline.NewQueue(conn, offline.WithEncryption(testCipher(t, 2)))
+
+	err = q.PushMany(heartbeats[1:])
+	require.NoError(t, err)
+
+	hh, err := q.PopMany(1)
+	require.NoError(t, err)
+
+	assert.Equal(t, heartbeats[1:], hh)
+
+	// only the undecryptable row is kept
+	stored := selectHeartbeatData(t, conn)
+	require.Len(t, stored, 1)
+
+	decrypted, err := offline.NewQueue(conn, offline.WithEncryption(testCipher(t, 1))).PopMany(10)
+	require.NoError(t, err)
+
+	assert.Equal(t, heartbeats[:1], decrypted)
+}
+
+func TestWithQueue_EncryptionMigratesPlaintext(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(f.Name())
+
+	conn := openDB(t, f.Name())
+
+	_, err = conn.Exec("CREATE TABLE heartbeat_2 (id TEXT, heartbeat TEXT)")
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile("testdata/heartbeat_two.json")
+	require.NoError(t, err)
+
+	insertHearbeatRecords(t, conn, []heartbeatRecord{
+		{
+			ID:        "1592868386.079084-file-debugging-wakatime-summary-/tmp/main.py-false",
+			Heartbeat: string(data),
+		},
+	})
+
+	c := testCipher(t, 1)
+
+	opt, err := offline.WithQueue(f.Name(), 10, offline.WithEncryption(c))
+	require.NoError(t, err)
+
+	stored := selectHeartbeatData(t, conn)
+	require.Len(t, stored, 1)
+
+	assert.True(t, strings.HasPrefix(stored[0], "enc:v1:"))
+
+	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		assert.Equal(t, testHeartbeats(), hh)
+
+		return nil, errors.New("failed")
+	})
+
+	_, err = handle([]heartbeat.Heartbeat{testHeartbeats()[0]})
+	require.Error(t, err)
+
+	stored = selectHeartbeatData(t, conn)
+	require.Len(t, stored, 2)
+
+	for _, data := range stored {
+		assert.True(t, strings.HasPrefix(data, "enc:v1:"))
+	}
+}
+
+func TestWithQueue_EncryptionFailsClosed(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(f.Name())
+
+	conn := openDB(t, f.Name())
+
+	_, err = conn.Exec("CREATE TABLE heartbeat_2 (id TEXT, heartbeat TEXT)")
+	require.NoError(t, err)
+
+	err = offline.NewQueue(conn, offline.WithEncryption(testCipher(t, 1))).PushMany(testHeartbeats()[1:])
+	require.NoError(t, err)
+
+	opt, err := offline.WithQueue(f.Name(), 10, offline.WithEncryption(testCipher(t, 2)))
+	require.NoError(t, err)
+
+	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		assert.Equal(t, testHeartbeats()[:1], hh)
+
+		return []heartbeat.Result{
+			{
+				Status:    http.StatusCreated,
+				Heartbeat: testHeartbeats()[0],
+			},
+		}, nil
+	})
+
+	_, err = handle([]heartbeat.Heartbeat{testHeartbeats()[0]})
+	require.NoError(t, err)
+
+	assert.Len(t, selectHeartbeatData(t, conn), 1)
+}
+
+func testCipher(t *testing.T, b byte) *offline.Cipher {
+	c, err := offline.NewCipher(bytes.Repeat([]byte{b}, 32))
+	require.NoError(t, err)
+
+	return c
+}
+
+func selectHeartbeatData(t *testing.T, conn *sql.DB) []string {
+	rows, err := conn.Query("SELECT heartbeat FROM heartbeat_2;")
+	require.NoError(t, err)
+
+	defer rows.Close()
+
+	var values []string
+
+	for rows.Next() {
+		var data string
+
+		err := rows.Scan(&data)
+		require.NoError(t, err)
+
+		values = append(values, data)
+	}
+
+	require.NoError(t, rows.Err())
+
+	return values
+}
+
 func testHeartbeats() []heartbeat.Heartbeat {
 	return []heartbeat.Heartbeat{
 		{
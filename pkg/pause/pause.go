@@ -0,0 +1,169 @@
+package pause
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// section is the section of the internal state file, which stores the pause state.
+	section = "pause"
+	// key is the key storing the end of the pause in RFC3339 format.
+	key = "until"
+)
+
+// State is the pause state.
+type State struct {
+	// Until is the time tracking resumes. Zero, if not paused.
+	Until time.Time
+}
+
+// Paused checks if tracking is paused at now.
+func (s State) Paused(now time.Time) bool {
+	return now.Before(s.Until)
+}
+
+// Status returns a human readable description of the state at now.
+func (s State) Status(now time.Time) string {
+	if !s.Paused(now) {
+		return "Tracking is active"
+	}
+
+	return fmt.Sprintf(
+		"Tracking is paused until %s (%s remaining)",
+		s.Until.Local().Format("2006-01-02 15:04"),
+		s.Until.Sub(now).Round(time.Minute),
+	)
+}
+
+// Load reads the pause state from the internal state file at fp. A missing
+// file results in the zero State.
+func Load(fp string) (State, error) {
+	if _, err := os.Stat(fp); os.IsNotExist(err) {
+		return State{}, nil
+	}
+
+	f, err := ini.Load(fp)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to load internal state file: %s", err)
+	}
+
+	value := f.Section(section).Key(key).String()
+	if value == "" {
+		return State{}, nil
+	}
+
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to parse pause end %q: %s", value, err)
+	}
+
+	return State{Until: until}, nil
+}
+
+// Save writes the pause state to the internal state file at fp, keeping
+// other sections of the file. A zero State removes the pause marker.
+func Save(fp string, s State) error {
+	f := ini.Empty()
+
+	if _, err := os.Stat(fp); err == nil {
+		f, err = ini.Load(fp)
+		if err != nil {
+			return fmt.Errorf("failed to load internal state file: %s", err)
+		}
+	}
+
+	if s.Until.IsZero() {
+		f.Section(section).DeleteKey(key)
+	} else {
+		f.Section(section).Key(key).SetValue(s.Until.Format(time.RFC3339))
+	}
+
+	if err := f.SaveTo(fp); err != nil {
+		return fmt.Errorf("failed to save internal state file: %s", err)
+	}
+
+	return nil
+}
+
+// ParseUntil parses the end of a pause relative to now. Accepts a duration
+// like "1h30m", a time of day like "17:30", which refers to the next
+// occurrence of that time, or an absolute time like "2006-01-02 15:04" or
+// in RFC3339 format.
+func ParseUntil(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("pause duration must be positive, got %q", s)
+		}
+
+		return now.Add(d), nil
+	}
+
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		until := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !until.After(now) {
+			until = time.Date(now.Year(), now.Month(), now.Day()+1, t.Hour(), t.Minute(), 0, 0, now.Location())
+		}
+
+		return until, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			if !t.After(now) {
+				return time.Time{}, fmt.Errorf("pause end %q is in the past", s)
+			}
+
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"invalid pause %q. must be a duration like 1h30m or a time like 17:30 or 2006-01-02 15:04",
+		s,
+	)
+}
+
+// Config contains pause configurations.
+type Config struct {
+	// FilePath is the internal state file storing the pause state.
+	FilePath string
+	// Trace optionally records heartbeats dropped while paused.
+	Trace *heartbeat.Trace
+}
+
+// WithPause initializes and returns a heartbeat handle option, which can be
+// used in a heartbeat processing pipeline to drop all heartbeats while
+// tracking is paused. It must run before any stage queueing heartbeats, so
+// that nothing is recorded during the pause.
+func WithPause(config Config) heartbeat.HandleOption {
+	return func(next heartbeat.Handle) heartbeat.Handle {
+		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			state, err := Load(config.FilePath)
+			if err != nil {
+				jww.WARN.Printf("failed to load pause state: %s", err)
+				return next(hh)
+			}
+
+			if state.Paused(time.Now()) {
+				jww.DEBUG.Printf("skipping %d heartbeat(s), because tracking is paused until %s",
+					len(hh), state.Until.Format(time.RFC3339))
+				config.Trace.Add("pause", "skipping %d heartbeat(s), because tracking is paused until %s",
+					len(hh), state.Until.Format(time.RFC3339))
+
+				return []heartbeat.Result{}, nil
+			}
+
+			return next(hh)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package pause_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/pause"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUntil(t *testing.T) {
+	now := time.Date(2021, 3, 26, 16, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		Input    string
+		Expected time.Time
+	}{
+		"duration": {
+			Input:    "1h30m",
+			Expected: time.Date(2021, 3, 26, 17, 30, 0, 0, time.UTC),
+		},
+		"time of day later today": {
+			Input:    "17:30",
+			Expected: time.Date(2021, 3, 26, 17, 30, 0, 0, time.UTC),
+		},
+		"time of day tomorrow": {
+			Input:    "09:00",
+			Expected: time.Date(2021, 3, 27, 9, 0, 0, 0, time.UTC),
+		},
+		"date and time": {
+			Input:    "2021-03-29 09:00",
+			Expected: time.Date(2021, 3, 29, 9, 0, 0, 0, time.UTC),
+		},
+		"rfc3339": {
+			Input:    "2021-03-29T09:00:00Z",
+			Expected: time.Date(2021, 3, 29, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			until, err := pause.ParseUntil(test.Input, now)
+			require.NoError(t, err)
+
+			assert.True(t, test.Expected.Equal(until), "expected %s, got %s", test.Expected, until)
+		})
+	}
+}
+
+func TestParseUntil_Err(t *testing.T) {
+	now := time.Date(2021, 3, 26, 16, 0, 0, 0, time.UTC)
+
+	for _, input := range []string{"-1h", "0s", "2021-03-25 09:00", "tomorrow", "25:00"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := pause.ParseUntil(input, now)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime-internal.cfg")
+
+	err = ioutil.WriteFile(fp, []byte("[other]\nkey = value\n"), 0600)
+	require.NoError(t, err)
+
+	until := time.Date(2021, 3, 26, 17, 30, 0, 0, time.UTC)
+
+	err = pause.Save(fp, pause.State{Until: until})
+	require.NoError(t, err)
+
+	state, err := pause.Load(fp)
+	require.NoError(t, err)
+
+	assert.True(t, until.Equal(state.Until))
+
+	err = pause.Save(fp, pause.State{})
+	require.NoError(t, err)
+
+	state, err = pause.Load(fp)
+	require.NoError(t, err)
+
+	assert.True(t, state.Until.IsZero())
+
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), "key = value")
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	state, err := pause.Load(filepath.Join(os.TempDir(), "nonexisting", ".wakatime-internal.cfg"))
+	require.NoError(t, err)
+
+	assert.Equal(t, pause.State{}, state)
+}
+
+func TestState_Status(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, "Tracking is active", pause.State{}.Status(now))
+	assert.Equal(t, "Tracking is active", pause.State{Until: now.Add(-time.Minute)}.Status(now))
+	assert.Equal(
+		t,
+		"Tracking is paused until "+now.Add(time.Hour).Format("2006-01-02 15:04")+" (1h0m0s remaining)",
+		pause.State{Until: now.Add(time.Hour)}.Status(now),
+	)
+}
+
+func TestWithPause(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	fp := filepath.Join(tmpDir, ".wakatime-internal.cfg")
+
+	tests := map[string]struct {
+		State      pause.State
+		NextCalled bool
+	}{
+		"paused": {
+			State: pause.State{Until: time.Now().Add(time.Hour)},
+		},
+		"pause expired": {
+			State:      pause.State{Until: time.Now().Add(-time.Hour)},
+			NextCalled: true,
+		},
+		"not paused": {
+			NextCalled: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := pause.Save(fp, test.State)
+			require.NoError(t, err)
+
+			var called bool
+
+			opt := pause.WithPause(pause.Config{FilePath: fp})
+
+			handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+				called = true
+
+				return []heartbeat.Result{{Status: 201}}, nil
+			})
+
+			results, err := handle([]heartbeat.Heartbeat{{Entity: "/tmp/main.go"}})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.NextCalled, called)
+
+			if !test.NextCalled {
+				assert.Empty(t, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package project
+
+import (
+	"regexp"
+)
+
+// MapBranch uses the [branchmap] config section to rename branches by
+// matching them with regex patterns. Capture groups can be used in the new
+// branch name, following the same syntax as [projectmap].
+//
+// For example:
+//
+//	[branchmap]
+//	feature/([A-Z]+-\d+)-.* = {0}
+//	main = master
+//
+// Will result in branch 'feature/PROJ-1234-add-login' being renamed to
+// 'PROJ-1234' and branch 'main' to 'master'. Returns the branch unchanged,
+// if no pattern matches.
+func MapBranch(branch string, patterns []MapPattern) string {
+	if branch == "" {
+		return branch
+	}
+
+	mapped, ok := applyPatterns(branch, patterns)
+	if !ok {
+		return branch
+	}
+
+	return mapped
+}
+
+// ExtractIssueKey extracts an issue key, like a ticket id, from a branch name.
+// Returns the first capture group of the pattern or, if the pattern does not
+// contain any capture groups, the whole match.
+func ExtractIssueKey(branch string, pattern *regexp.Regexp) (string, bool) {
+	if branch == "" || pattern == nil {
+		return "", false
+	}
+
+	matches := pattern.FindStringSubmatch(branch)
+
+	switch {
+	case len(matches) == 0:
+		return "", false
+	case len(matches) > 1:
+		return matches[1], matches[1] != ""
+	default:
+		return matches[0], matches[0] != ""
+	}
+}
@@ -0,0 +1,130 @@
+package project_test
+
+import (
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapBranch(t *testing.T) {
+	patterns := []project.MapPattern{
+		{
+			Name:  "{0}",
+			Regex: regexp.MustCompile(`^feature/([A-Z]+-\d+)-.*`),
+		},
+		{
+			Name:  "master",
+			Regex: regexp.MustCompile("^main$"),
+		},
+	}
+
+	tests := map[string]struct {
+		Branch   string
+		Expected string
+	}{
+		"capture group": {
+			Branch:   "feature/PROJ-1234-add-login",
+			Expected: "PROJ-1234",
+		},
+		"rename": {
+			Branch:   "main",
+			Expected: "master",
+		},
+		"no match": {
+			Branch:   "develop",
+			Expected: "develop",
+		},
+		"empty": {
+			Branch:   "",
+			Expected: "",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, project.MapBranch(test.Branch, patterns))
+		})
+	}
+}
+
+func TestExtractIssueKey(t *testing.T) {
+	tests := map[string]struct {
+		Branch   string
+		Pattern  *regexp.Regexp
+		Expected string
+		OK       bool
+	}{
+		"capture group": {
+			Branch:   "feature/PROJ-1234-add-login",
+			Pattern:  regexp.MustCompile(`/([A-Z]+-\d+)`),
+			Expected: "PROJ-1234",
+			OK:       true,
+		},
+		"whole match": {
+			Branch:   "bugfix/ABC-42",
+			Pattern:  regexp.MustCompile(`[A-Z]+-\d+`),
+			Expected: "ABC-42",
+			OK:       true,
+		},
+		"no match": {
+			Branch:  "master",
+			Pattern: regexp.MustCompile(`[A-Z]+-\d+`),
+		},
+		"no pattern": {
+			Branch: "feature/PROJ-1234-add-login",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			issueKey, ok := project.ExtractIssueKey(test.Branch, test.Pattern)
+
+			assert.Equal(t, test.OK, ok)
+			assert.Equal(t, test.Expected, issueKey)
+		})
+	}
+}
+
+func TestWithDetection_BranchMapAndIssueKey(t *testing.T) {
+	fp, tearDown := setupTestGitBasicBranchWithSlash(t)
+	defer tearDown()
+
+	opt := project.WithDetection(project.Config{
+		BranchMapPatterns: []project.MapPattern{
+			{
+				Name:  "feat-{0}",
+				Regex: regexp.MustCompile(`^feature/(\w+)$`),
+			},
+		},
+		IssueKeyPattern: regexp.MustCompile(`^feature/(\w+)$`),
+	})
+
+	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		assert.Equal(t, []heartbeat.Heartbeat{
+			{
+				Entity:      path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+				EntityType:  heartbeat.FileType,
+				IssueKey:    heartbeat.String("detection"),
+				Project:     heartbeat.String("wakatime-cli"),
+				ProjectPath: path.Join(fp, "wakatime-cli"),
+				Branch:      heartbeat.String("feat-detection"),
+			},
+		}, hh)
+
+		return nil, nil
+	})
+
+	_, err := handle([]heartbeat.Heartbeat{
+		{
+			EntityType: heartbeat.FileType,
+			Entity:     path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+		},
+	})
+	require.NoError(t, err)
+}
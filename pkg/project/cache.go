@@ -0,0 +1,297 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const (
+	// cacheMaxEntries is the maximum number of directories kept in the cache.
+	// Least recently used entries will be removed first.
+	cacheMaxEntries = 1000
+	// cacheTouchInterval is the minimum duration between updates of the last
+	// used timestamp of an entry, to avoid rewriting the cache file on every hit.
+	cacheTouchInterval = time.Hour
+)
+
+// Cache is a persistent cache of project detection results per directory. As
+// the cli runs once per heartbeat, it allows to skip project file and revision
+// control detection for directories already processed by an earlier invocation.
+//
+// An entry is invalidated as soon as one of the files influencing detection
+// changes. These are the .wakatime-project and .git files of the directory and
+// all its parents, the HEAD or branch file of the nearest repository and the
+// wakatime config file.
+type Cache struct {
+	filepath       string
+	configFilepath string
+	data           *cacheData
+	changed        bool
+}
+
+type cacheData struct {
+	ConfigModTime int64                  `json:"config_mtime"`
+	Entries       map[string]*CacheEntry `json:"entries"`
+}
+
+// CacheEntry contains the detection results of a directory.
+type CacheEntry struct {
+	// File is the result of .wakatime-project file detection. Nil if no file was found.
+	File *Result `json:"file"`
+	// RevControl is the result of revision control detection. Nil if not detected.
+	RevControl *Result `json:"rev_control"`
+	// ModTimes contains the modification times of all files, which are used
+	// to invalidate this entry.
+	ModTimes map[string]int64 `json:"mtimes"`
+	// LastUsed is the unix timestamp of the last access to this entry.
+	LastUsed int64 `json:"last_used"`
+}
+
+// NewCache creates a new Cache instance, which is stored at filepath and is
+// invalidated completely on every change of the config file at configFilepath.
+// The cache file is read lazily on first access.
+func NewCache(filepath, configFilepath string) *Cache {
+	return &Cache{
+		filepath:       filepath,
+		configFilepath: configFilepath,
+	}
+}
+
+// Get returns the cached entry for a directory. Returns false, if no entry
+// exists or the entry has been invalidated.
+func (c *Cache) Get(dir string) (CacheEntry, bool) {
+	c.load()
+
+	entry, ok := c.data.Entries[dir]
+	if !ok {
+		return CacheEntry{}, false
+	}
+
+	for fp, mtime := range entry.ModTimes {
+		if modTime(fp) != mtime {
+			jww.DEBUG.Printf("project cache entry for %q invalidated by change of %q", dir, fp)
+
+			delete(c.data.Entries, dir)
+			c.changed = true
+
+			return CacheEntry{}, false
+		}
+	}
+
+	now := time.Now()
+	if now.Sub(time.Unix(entry.LastUsed, 0)) > cacheTouchInterval {
+		entry.LastUsed = now.Unix()
+		c.changed = true
+	}
+
+	return *entry, true
+}
+
+// Set stores detection results for a directory. The files used for
+// invalidation are detected and their modification times recorded.
+func (c *Cache) Set(dir string, file, revControl *Result) {
+	c.load()
+
+	c.data.Entries[dir] = &CacheEntry{
+		File:       file,
+		RevControl: revControl,
+		ModTimes:   watchedModTimes(dir),
+		LastUsed:   time.Now().Unix(),
+	}
+	c.changed = true
+}
+
+// Save persists the cache to disk, if it has been modified.
+func (c *Cache) Save() error {
+	if !c.changed || c.data == nil {
+		return nil
+	}
+
+	if len(c.data.Entries) > cacheMaxEntries {
+		c.evict(len(c.data.Entries) - cacheMaxEntries)
+	}
+
+	data, err := json.Marshal(c.data)
+	if err != nil {
+		return fmt.Errorf("failed to json encode cache: %s", err)
+	}
+
+	// write to a temporary file and rename it afterwards, so that concurrently
+	// running processes never read a partially written cache file.
+	tmpFile, err := ioutil.TempFile(filepath.Dir(c.filepath), filepath.Base(c.filepath))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cache file: %s", err)
+	}
+
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write temporary cache file: %s", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary cache file: %s", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), c.filepath); err != nil {
+		return fmt.Errorf("failed to move temporary cache file to %q: %s", c.filepath, err)
+	}
+
+	c.changed = false
+
+	return nil
+}
+
+// load reads the cache file from disk, if not already done. Any failure
+// results in an empty cache.
+func (c *Cache) load() {
+	if c.data != nil {
+		return
+	}
+
+	c.data = &cacheData{
+		Entries: make(map[string]*CacheEntry),
+	}
+
+	data, err := ioutil.ReadFile(c.filepath)
+	if err != nil && !os.IsNotExist(err) {
+		jww.DEBUG.Printf("failed to read project cache file %q: %s", c.filepath, err)
+	}
+
+	if err == nil {
+		var parsed cacheData
+
+		err = json.Unmarshal(data, &parsed)
+		if err != nil {
+			jww.DEBUG.Printf("failed to parse project cache file %q: %s", c.filepath, err)
+		} else if parsed.Entries != nil {
+			c.data = &parsed
+		}
+	}
+
+	configModTime := modTime(c.configFilepath)
+	if c.data.ConfigModTime != configModTime {
+		c.data = &cacheData{
+			ConfigModTime: configModTime,
+			Entries:       make(map[string]*CacheEntry),
+		}
+		c.changed = true
+	}
+}
+
+// evict removes the n least recently used entries.
+func (c *Cache) evict(n int) {
+	dirs := make([]string, 0, len(c.data.Entries))
+	for dir := range c.data.Entries {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return c.data.Entries[dirs[i]].LastUsed < c.data.Entries[dirs[j]].LastUsed
+	})
+
+	for _, dir := range dirs[:n] {
+		delete(c.data.Entries, dir)
+	}
+}
+
+// watchedModTimes returns the modification times of all files influencing
+// project detection for a directory. These are the .wakatime-project files of
+// the directory and all its parents, and the repository markers up to the
+// nearest repository, including its HEAD or branch file.
+func watchedModTimes(dir string) map[string]int64 {
+	mtimes := make(map[string]int64)
+
+	var gitFound, hgFound, svnFound bool
+
+	for {
+		fp := filepath.Join(dir, defaultProjectFile)
+		mtimes[fp] = modTime(fp)
+
+		if !gitFound {
+			fp = filepath.Join(dir, ".git")
+			mtimes[fp] = modTime(fp)
+
+			if head, ok := findGitHead(fp); ok {
+				mtimes[head] = modTime(head)
+				gitFound = true
+			}
+		}
+
+		if !hgFound {
+			fp = filepath.Join(dir, ".hg")
+			mtimes[fp] = modTime(fp)
+
+			if fileExists(fp) {
+				branch := filepath.Join(fp, "branch")
+				mtimes[branch] = modTime(branch)
+				hgFound = true
+			}
+		}
+
+		if !svnFound {
+			fp = filepath.Join(dir, ".svn")
+			mtimes[fp] = modTime(fp)
+
+			if fileExists(fp) {
+				wcdb := filepath.Join(fp, "wc.db")
+				mtimes[wcdb] = modTime(wcdb)
+				svnFound = true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+
+		dir = parent
+	}
+
+	return mtimes
+}
+
+// findGitHead returns the HEAD file of a .git folder. Resolves the gitdir,
+// if .git is a file, as used for worktrees and submodules.
+func findGitHead(fp string) (string, bool) {
+	info, err := os.Stat(fp)
+	if err != nil {
+		return "", false
+	}
+
+	if info.IsDir() {
+		return filepath.Join(fp, "HEAD"), true
+	}
+
+	gitdir, err := findGitdir(fp)
+	if err != nil || gitdir == "" {
+		return "", false
+	}
+
+	return filepath.Join(gitdir, "HEAD"), true
+}
+
+// modTime returns the modification time of a file in nanoseconds. Returns 0 if
+// the file does not exist and -1 for directories, as only their existence is
+// relevant and their modification time changes too often.
+func modTime(fp string) int64 {
+	info, err := os.Stat(fp)
+	if err != nil {
+		return 0
+	}
+
+	if info.IsDir() {
+		return -1
+	}
+
+	return info.ModTime().UnixNano()
+}
@@ -0,0 +1,162 @@
+package project_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_SetGet(t *testing.T) {
+	fp, tearDown := setupTestGitBasic(t)
+	defer tearDown()
+
+	cacheFile := path.Join(fp, "cache.json")
+	dir := path.Join(fp, "wakatime-cli/src/pkg")
+
+	c := project.NewCache(cacheFile, "")
+
+	_, ok := c.Get(dir)
+	assert.False(t, ok)
+
+	c.Set(dir, nil, &project.Result{
+		Project: "wakatime-cli",
+		Branch:  "master",
+		Folder:  path.Join(fp, "wakatime-cli"),
+	})
+
+	err := c.Save()
+	require.NoError(t, err)
+
+	// read from disk
+	c = project.NewCache(cacheFile, "")
+
+	entry, ok := c.Get(dir)
+	require.True(t, ok)
+
+	assert.Nil(t, entry.File)
+	assert.Equal(t, &project.Result{
+		Project: "wakatime-cli",
+		Branch:  "master",
+		Folder:  path.Join(fp, "wakatime-cli"),
+	}, entry.RevControl)
+}
+
+func TestCache_Get_Invalidated(t *testing.T) {
+	tests := map[string]func(t *testing.T, fp string){
+		"git head changed": func(t *testing.T, fp string) {
+			touch(t, path.Join(fp, "wakatime-cli/.git/HEAD"))
+		},
+		"project file created": func(t *testing.T, fp string) {
+			copyFile(t, "testdata/.wakatime-project", path.Join(fp, "wakatime-cli/src/.wakatime-project"))
+		},
+		"nested repository created": func(t *testing.T, fp string) {
+			err := os.Mkdir(path.Join(fp, "wakatime-cli/src/.git"), os.FileMode(int(0700)))
+			require.NoError(t, err)
+		},
+		"config file changed": func(t *testing.T, fp string) {
+			touch(t, path.Join(fp, "wakatime.cfg"))
+		},
+	}
+
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			fp, tearDown := setupTestGitBasic(t)
+			defer tearDown()
+
+			cacheFile := path.Join(fp, "cache.json")
+			configFile := path.Join(fp, "wakatime.cfg")
+			dir := path.Join(fp, "wakatime-cli/src/pkg")
+
+			err := ioutil.WriteFile(configFile, []byte("[settings]\n"), 0600)
+			require.NoError(t, err)
+
+			c := project.NewCache(cacheFile, configFile)
+			c.Set(dir, nil, &project.Result{Project: "wakatime-cli", Branch: "master"})
+
+			err = c.Save()
+			require.NoError(t, err)
+
+			modify(t, fp)
+
+			c = project.NewCache(cacheFile, configFile)
+
+			_, ok := c.Get(dir)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestWithDetection_Cache(t *testing.T) {
+	fp, tearDown := setupTestGitBasic(t)
+	defer tearDown()
+
+	entity := path.Join(fp, "wakatime-cli/src/pkg/file.go")
+	cacheFile := path.Join(fp, "cache.json")
+
+	detect := func() heartbeat.Heartbeat {
+		var result heartbeat.Heartbeat
+
+		opt := project.WithDetection(project.Config{
+			Cache: project.NewCache(cacheFile, ""),
+		})
+
+		handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			result = hh[0]
+			return nil, nil
+		})
+
+		_, err := handle([]heartbeat.Heartbeat{
+			{
+				EntityType: heartbeat.FileType,
+				Entity:     entity,
+			},
+		})
+		require.NoError(t, err)
+
+		return result
+	}
+
+	h := detect()
+	assert.Equal(t, heartbeat.String("wakatime-cli"), h.Project)
+	assert.Equal(t, heartbeat.String("master"), h.Branch)
+
+	// without .git/config git detection would fail, so a detected project
+	// proves the result was taken from cache.
+	err := os.Remove(path.Join(fp, "wakatime-cli/.git/config"))
+	require.NoError(t, err)
+
+	h = detect()
+	assert.Equal(t, heartbeat.String("wakatime-cli"), h.Project)
+	assert.Equal(t, heartbeat.String("master"), h.Branch)
+
+	// switching branch invalidates the cached entry
+	copyFile(t, "testdata/git_basic/config", path.Join(fp, "wakatime-cli/.git/config"))
+	copyFile(t, "testdata/git_basic/HEAD_WITH_SLASH", path.Join(fp, "wakatime-cli/.git/HEAD"))
+	touch(t, path.Join(fp, "wakatime-cli/.git/HEAD"))
+
+	h = detect()
+	assert.Equal(t, heartbeat.String("wakatime-cli"), h.Project)
+	assert.Equal(t, heartbeat.String("feature/detection"), h.Branch)
+}
+
+// touch creates or updates the modification time of a file to a time in
+// the future, so changes are detected independent of file system precision.
+func touch(t *testing.T, fp string) {
+	if _, err := os.Stat(fp); os.IsNotExist(err) {
+		err = ioutil.WriteFile(fp, nil, 0600)
+		require.NoError(t, err)
+	}
+
+	future := time.Now().Add(time.Hour)
+
+	err := os.Chtimes(fp, future, future)
+	require.NoError(t, err)
+}
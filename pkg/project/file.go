@@ -35,7 +35,9 @@ func (f File) Detect() (Result, bool, error) {
 			Err(fmt.Sprintf("error reading file: %s", err))
 	}
 
-	result := Result{}
+	result := Result{
+		Folder: path.Dir(fp),
+	}
 
 	if len(lines) > 0 {
 		result.Project = strings.TrimSpace(lines[0])
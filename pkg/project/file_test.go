@@ -23,9 +23,13 @@ func TestFile_Detect_FileExists(t *testing.T) {
 	result, detected, err := f.Detect()
 	require.NoError(t, err)
 
+	folder, err := realpath.Realpath("testdata")
+	require.NoError(t, err)
+
 	expected := project.Result{
 		Project: "wakatime-cli",
 		Branch:  "master",
+		Folder:  folder,
 	}
 
 	assert.True(t, detected)
@@ -56,9 +60,13 @@ func TestFile_Detect_ParentFolderExists(t *testing.T) {
 	result, detected, err := f.Detect()
 	require.NoError(t, err)
 
+	realpathTmpDir, err := realpath.Realpath(tmpDir)
+	require.NoError(t, err)
+
 	expected := project.Result{
 		Project: "wakatime-cli",
 		Branch:  "master",
+		Folder:  realpathTmpDir,
 	}
 
 	assert.True(t, detected)
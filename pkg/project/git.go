@@ -4,9 +4,10 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
 	jww "github.com/spf13/jwalterweatherman"
 	"github.com/yookoala/realpath"
 )
@@ -16,7 +17,7 @@ type Git struct {
 	// Filepath conaints the entity path.
 	Filepath string
 	// SubmodulePatterns will be matched against the submodule path and if matching, will skip it.
-	SubmodulePatterns []*regexp.Regexp
+	SubmodulePatterns []matcher.Matcher
 }
 
 // Detect gets information about the git project for a given file.
@@ -34,7 +35,7 @@ func (g Git) Detect() (Result, bool, error) {
 	}
 
 	// Find for submodule takes priority if enabled
-	gitdirSubmodule, ok, err := findSubmodule(fp, g.SubmodulePatterns)
+	gitdirSubmodule, submoduleFolder, ok, err := findSubmodule(fp, g.SubmodulePatterns)
 	if err != nil {
 		return Result{}, false,
 			Err(fmt.Sprintf("failed to validate submodule: %s", err))
@@ -55,6 +56,7 @@ func (g Git) Detect() (Result, bool, error) {
 		return Result{
 			Project: project,
 			Branch:  branch,
+			Folder:  submoduleFolder,
 		}, true, nil
 	}
 
@@ -76,6 +78,7 @@ func (g Git) Detect() (Result, bool, error) {
 		return Result{
 			Project: project,
 			Branch:  branch,
+			Folder:  path.Dir(gitConfigFile),
 		}, true, nil
 	}
 
@@ -115,6 +118,7 @@ func (g Git) Detect() (Result, bool, error) {
 		return Result{
 			Project: project,
 			Branch:  branch,
+			Folder:  gitConfigFile,
 		}, true, nil
 	}
 
@@ -134,6 +138,7 @@ func (g Git) Detect() (Result, bool, error) {
 		return Result{
 			Project: project,
 			Branch:  branch,
+			Folder:  gitConfigFile,
 		}, true, nil
 	}
 
@@ -153,32 +158,33 @@ func findGitConfigFile(fp string, directory string, match string) (string, bool)
 	return findGitConfigFile(dir, directory, match)
 }
 
-func findSubmodule(fp string, patterns []*regexp.Regexp) (string, bool, error) {
+// findSubmodule returns the gitdir and the working directory of a submodule.
+func findSubmodule(fp string, patterns []matcher.Matcher) (string, string, bool, error) {
 	if !shouldTakeSubmodule(fp, patterns) {
-		return "", false, nil
+		return "", "", false, nil
 	}
 
 	gitConfigFile, ok := findGitConfigFile(fp, "", ".git")
 	if !ok {
-		return "", false, nil
+		return "", "", false, nil
 	}
 
 	gitdir, err := findGitdir(path.Join(gitConfigFile, ".git"))
 	if err != nil {
-		return "", false,
+		return "", "", false,
 			Err(fmt.Sprintf("error finding gitdir for submodule: %s", err))
 	}
 
 	if strings.Contains(gitdir, "modules") {
-		return gitdir, true, nil
+		return gitdir, gitConfigFile, true, nil
 	}
 
-	return "", false, nil
+	return "", "", false, nil
 }
 
 // shouldTakeSubmodule checks a filepath against the passed in regex patterns to determine,
 // if submodule filepath should be taken.
-func shouldTakeSubmodule(fp string, patterns []*regexp.Regexp) bool {
+func shouldTakeSubmodule(fp string, patterns []matcher.Matcher) bool {
 	for _, p := range patterns {
 		if p.MatchString(fp) {
 			return false
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 	"github.com/wakatime/wakatime-cli/pkg/project"
 
 	"github.com/stretchr/testify/assert"
@@ -29,6 +30,7 @@ func TestGit_Detect(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "master",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
@@ -47,6 +49,7 @@ func TestGit_Detect_BranchWithSlash(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "feature/detection",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
@@ -65,6 +68,7 @@ func TestGit_Detect_DetachedHead(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
@@ -75,18 +79,22 @@ func TestGit_Detect_GitConfigFile_File(t *testing.T) {
 	tests := map[string]struct {
 		Filepath string
 		Project  string
+		Folder   string
 	}{
 		"main_repo": {
 			Filepath: path.Join(fp, "wakatime-cli/src/pkg/file.go"),
 			Project:  "wakatime-cli",
+			Folder:   path.Join(fp, "wakatime-cli"),
 		},
 		"relative_path": {
 			Filepath: path.Join(fp, "feed/src/pkg/file.go"),
 			Project:  "feed",
+			Folder:   path.Join(fp, "feed"),
 		},
 		"absolute_pasth": {
 			Filepath: path.Join(fp, "mobile/src/pkg/file.go"),
 			Project:  "mobile",
+			Folder:   path.Join(fp, "mobile"),
 		},
 	}
 
@@ -103,6 +111,7 @@ func TestGit_Detect_GitConfigFile_File(t *testing.T) {
 			assert.Equal(t, project.Result{
 				Project: test.Project,
 				Branch:  "feature/list-elements",
+				Folder:  test.Folder,
 			}, result)
 		})
 	}
@@ -123,6 +132,7 @@ func TestGit_Detect_Worktree(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "feature/api",
+		Folder:  path.Join(fp, "api"),
 	}, result)
 }
 
@@ -132,7 +142,7 @@ func TestGit_Detect_Submodule(t *testing.T) {
 
 	g := project.Git{
 		Filepath:          path.Join(fp, "wakatime-cli/lib/billing/src/lib/lib.cpp"),
-		SubmodulePatterns: []*regexp.Regexp{regexp.MustCompile("not_matching")},
+		SubmodulePatterns: []matcher.Matcher{regexp.MustCompile("not_matching")},
 	}
 
 	result, detected, err := g.Detect()
@@ -142,6 +152,7 @@ func TestGit_Detect_Submodule(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "billing",
 		Branch:  "master",
+		Folder:  path.Join(fp, "wakatime-cli/lib/billing"),
 	}, result)
 }
 
@@ -151,7 +162,7 @@ func TestGit_Detect_SubmoduleDisabled(t *testing.T) {
 
 	g := project.Git{
 		Filepath:          path.Join(fp, "wakatime-cli/lib/billing/src/lib/lib.cpp"),
-		SubmodulePatterns: []*regexp.Regexp{regexp.MustCompile(".*billing.*")},
+		SubmodulePatterns: []matcher.Matcher{regexp.MustCompile(".*billing.*")},
 	}
 
 	result, detected, err := g.Detect()
@@ -161,6 +172,7 @@ func TestGit_Detect_SubmoduleDisabled(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "feature/billing",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
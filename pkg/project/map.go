@@ -2,6 +2,7 @@ package project
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/slongfield/pyfmt"
 	jww "github.com/spf13/jwalterweatherman"
@@ -18,19 +19,22 @@ type Map struct {
 // with regex patterns. Project maps go under the [projectmap] config section.
 //
 // For example:
-// 	[projectmap]
+//
+//	[projectmap]
 //	/home/user/projects/foo = new project name
 //	/home/user/projects/bar(\d+)/ = project{0}
+//	"glob:~/work/*/" = client-{0} | branch=main | category=code reviewing
 //
 // Will result in file '/home/user/projects/foo/src/main.c' to have
 // project name 'new project name' and file '/home/user/projects/bar42/main.c'
-// to have project name 'project42'.
+// to have project name 'project42'. Optionally an entry can set the branch
+// and the category, where the branch can use capture groups as well.
 func (m Map) Detect() (Result, bool, error) {
 	if len(m.Patterns) == 0 {
 		return Result{}, false, nil
 	}
 
-	result, ok, err := matchPattern(m.Filepath, m.Patterns)
+	match, ok, err := MatchMap(m.Filepath, m.Patterns)
 	if err != nil {
 		return Result{}, false,
 			Err(fmt.Sprintf("error matching pattern: %s", err))
@@ -39,40 +43,105 @@ func (m Map) Detect() (Result, bool, error) {
 	}
 
 	return Result{
-		Project: result,
+		Project: match.Project,
+		Branch:  match.Branch,
 	}, true, nil
 }
 
-// matchPattern matches regex against entity's path to find project name.
-func matchPattern(fp string, patterns []MapPattern) (string, bool, error) {
-	fp, err := realpath.Realpath(fp)
+// MapMatch is the result of matching a file against [projectmap] patterns.
+type MapMatch struct {
+	// Pattern is the first matching pattern.
+	Pattern MapPattern
+	// Project is the formatted project name.
+	Project string
+	// Branch is the formatted branch name. Empty, if the pattern has no branch template.
+	Branch string
+}
+
+// MatchMap matches regex against entity's path and returns the first matching
+// pattern with the formatted project and branch names. Paths which cannot be
+// resolved, like nonexistent files, are matched by their absolute path.
+func MatchMap(fp string, patterns []MapPattern) (MapMatch, bool, error) {
+	resolved, err := realpath.Realpath(fp)
 	if err != nil {
-		return "", false,
-			Err(fmt.Errorf("failed to get the real path: %w", err).Error())
+		jww.DEBUG.Printf("failed to get the real path of %q: %s", fp, err)
+
+		resolved, err = filepath.Abs(fp)
+		if err != nil {
+			return MapMatch{}, false,
+				Err(fmt.Errorf("failed to get the absolute path: %w", err).Error())
+		}
 	}
 
+	fp = resolved
+
 	for _, pattern := range patterns {
-		if pattern.Regex.MatchString(fp) {
-			matches := pattern.Regex.FindStringSubmatch(fp)
-			if len(matches) > 0 {
-				params := make([]interface{}, len(matches[1:]))
-				for i, v := range matches[1:] {
-					params[i] = v
-				}
-
-				result, err := pyfmt.Fmt(pattern.Name, params...)
-
-				if err != nil {
-					jww.ERROR.Printf("error formatting %q: %s", pattern.Name, err)
-					continue
-				}
-
-				return result, true, nil
+		params, ok := matchParams(fp, pattern)
+		if !ok {
+			continue
+		}
+
+		project, err := pyfmt.Fmt(pattern.Name, params...)
+		if err != nil {
+			jww.ERROR.Printf("error formatting %q: %s", pattern.Name, err)
+			continue
+		}
+
+		var branch string
+
+		if pattern.Branch != "" {
+			branch, err = pyfmt.Fmt(pattern.Branch, params...)
+			if err != nil {
+				jww.ERROR.Printf("error formatting %q: %s", pattern.Branch, err)
+				continue
 			}
 		}
+
+		return MapMatch{
+			Pattern: pattern,
+			Project: project,
+			Branch:  branch,
+		}, true, nil
+	}
+
+	return MapMatch{}, false, nil
+}
+
+// applyPatterns matches subject against the patterns and formats the name of
+// the first matching pattern with the regex capture groups.
+func applyPatterns(subject string, patterns []MapPattern) (string, bool) {
+	for _, pattern := range patterns {
+		params, ok := matchParams(subject, pattern)
+		if !ok {
+			continue
+		}
+
+		result, err := pyfmt.Fmt(pattern.Name, params...)
+		if err != nil {
+			jww.ERROR.Printf("error formatting %q: %s", pattern.Name, err)
+			continue
+		}
+
+		return result, true
+	}
+
+	return "", false
+}
+
+// matchParams matches subject against the pattern and returns the capture
+// groups as template params.
+func matchParams(subject string, pattern MapPattern) ([]interface{}, bool) {
+	matches := pattern.Regex.FindStringSubmatch(subject)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	params := make([]interface{}, len(matches[1:]))
+	for i, v := range matches[1:] {
+		params[i] = v
 	}
 
-	return "", false, nil
+	return params, true
 }
 
 // String returns its name.
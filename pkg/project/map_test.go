@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
 	"github.com/wakatime/wakatime-cli/pkg/project"
 
 	"github.com/stretchr/testify/assert"
@@ -70,6 +71,76 @@ func TestMap_Detect_RegexReplace(t *testing.T) {
 	assert.Equal(t, "my-project-2-data", result.Project)
 }
 
+func TestMap_Detect_Branch(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	m := project.Map{
+		Filepath: "testdata/entity.any",
+		Patterns: []project.MapPattern{
+			{
+				Name:   "my-project",
+				Regex:  regexp.MustCompile(filepath.Join(wd, "test(\\w+)")),
+				Branch: "branch-{0}",
+			},
+		},
+	}
+
+	result, detected, err := m.Detect()
+	require.NoError(t, err)
+
+	assert.True(t, detected)
+	assert.Equal(t, "my-project", result.Project)
+	assert.Equal(t, "branch-data", result.Branch)
+}
+
+func TestMatchMap(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	category := heartbeat.CodeReviewingCategory
+
+	patterns := []project.MapPattern{
+		{
+			Name:  "other",
+			Regex: regexp.MustCompile(filepath.Join(wd, "path/to/otherfolder")),
+		},
+		{
+			Name:     "my-project-{0}",
+			Regex:    regexp.MustCompile(filepath.Join(wd, "test(\\w+)")),
+			Category: &category,
+			Rule:     "test(\\w+) = my-project-{0} | category=code reviewing",
+		},
+	}
+
+	match, ok, err := project.MatchMap("testdata/entity.any", patterns)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, project.MapMatch{
+		Pattern: patterns[1],
+		Project: "my-project-data",
+	}, match)
+}
+
+func TestMatchMap_NonExistingPath(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	patterns := []project.MapPattern{
+		{
+			Name:  "my-project-{0}",
+			Regex: regexp.MustCompile(filepath.Join(wd, "missing/(\\w+)/")),
+		},
+	}
+
+	match, ok, err := project.MatchMap("missing/./client/../acme/main.go", patterns)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, "my-project-acme", match.Project)
+}
+
 func TestMap_Detect_NoMatch(t *testing.T) {
 	wd, err := os.Getwd()
 	require.NoError(t, err)
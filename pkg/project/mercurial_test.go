@@ -27,6 +27,7 @@ func TestMercurial_Detect(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "billing",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
@@ -45,6 +46,7 @@ func TestMercurial_Detect_BranchWithSlash(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "feature/billing",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
@@ -63,6 +65,7 @@ func TestMercurial_Detect_NoBranch(t *testing.T) {
 	assert.Equal(t, project.Result{
 		Project: "wakatime-cli",
 		Branch:  "default",
+		Folder:  path.Join(fp, "wakatime-cli"),
 	}, result)
 }
 
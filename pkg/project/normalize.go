@@ -0,0 +1,92 @@
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NormalizeConfig defines how detected project names are normalized, so that
+// the same project is reported under one name independent of how it was cloned.
+type NormalizeConfig struct {
+	// Lowercase folds project names to lower case.
+	Lowercase bool
+	// StripSuffixes are patterns anchored at the end of the string, like
+	// `\.git$` or `-worktree-\d+$`, which are removed from project names.
+	StripSuffixes []*regexp.Regexp
+	// Replace contains regex replace rules, applied in order.
+	Replace []ReplacePattern
+	// Aliases maps lower case project names to their canonical name, so that
+	// lookup is case insensitive. See LowercaseAliases.
+	Aliases map[string]string
+}
+
+// ReplacePattern contains a regex replace rule.
+type ReplacePattern struct {
+	// Regex is the regular expression to match.
+	Regex *regexp.Regexp
+	// Replacement is the replacement string, which can reference capture groups via $1.
+	Replacement string
+}
+
+// Normalize applies the normalization rules to a project name in the order
+// case folding, suffix stripping, replace rules and aliases. Returns the
+// original name, if normalization would result in an empty name.
+func Normalize(project string, c NormalizeConfig) string {
+	if project == "" {
+		return project
+	}
+
+	normalized := project
+
+	if c.Lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+
+	for _, suffix := range c.StripSuffixes {
+		normalized = suffix.ReplaceAllString(normalized, "")
+	}
+
+	for _, r := range c.Replace {
+		normalized = r.Regex.ReplaceAllString(normalized, r.Replacement)
+	}
+
+	if name, ok := c.Aliases[strings.ToLower(normalized)]; ok {
+		normalized = name
+	}
+
+	if normalized == "" {
+		return project
+	}
+
+	return normalized
+}
+
+// LowercaseAliases returns aliases with lower case keys for use in
+// NormalizeConfig. Returns an error, if aliases only differ by case.
+func LowercaseAliases(aliases map[string]string) (map[string]string, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	lowercased := make(map[string]string, len(aliases))
+	original := make(map[string]string, len(aliases))
+
+	for alias, name := range aliases {
+		key := strings.ToLower(strings.TrimSpace(alias))
+
+		if other, ok := original[key]; ok {
+			// sort for a deterministic error message
+			if other > alias {
+				other, alias = alias, other
+			}
+
+			return nil, fmt.Errorf("project aliases %q and %q only differ by case", other, alias)
+		}
+
+		lowercased[key] = name
+		original[key] = alias
+	}
+
+	return lowercased, nil
+}
@@ -0,0 +1,168 @@
+package project_test
+
+import (
+	"path"
+	"regexp"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/project"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	config := project.NormalizeConfig{
+		Lowercase: true,
+		StripSuffixes: []*regexp.Regexp{
+			regexp.MustCompile(`\.git$`),
+			regexp.MustCompile(`-main$`),
+			regexp.MustCompile(`[-_]worktree-?\d+$`),
+		},
+		Replace: []project.ReplacePattern{
+			{
+				Regex:       regexp.MustCompile(`^acme-(\w+)$`),
+				Replacement: "$1",
+			},
+		},
+		Aliases: map[string]string{
+			"backend": "api",
+		},
+	}
+
+	tests := map[string]struct {
+		Project  string
+		Expected string
+	}{
+		"case folding": {
+			Project:  "API",
+			Expected: "api",
+		},
+		"git suffix": {
+			Project:  "api.git",
+			Expected: "api",
+		},
+		"branch suffix": {
+			Project:  "api-main",
+			Expected: "api",
+		},
+		"worktree suffix": {
+			Project:  "api_worktree2",
+			Expected: "api",
+		},
+		"replace": {
+			Project:  "acme-api",
+			Expected: "api",
+		},
+		"alias": {
+			Project:  "Backend.git",
+			Expected: "api",
+		},
+		"unchanged": {
+			Project:  "billing",
+			Expected: "billing",
+		},
+		"empty": {
+			Project:  "",
+			Expected: "",
+		},
+		"empty result keeps original": {
+			Project:  "-main",
+			Expected: "-main",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, project.Normalize(test.Project, config))
+		})
+	}
+}
+
+func TestNormalize_EmptyConfig(t *testing.T) {
+	assert.Equal(t, "API.git", project.Normalize("API.git", project.NormalizeConfig{}))
+}
+
+func TestNormalize_AliasCaseInsensitive(t *testing.T) {
+	config := project.NormalizeConfig{
+		Aliases: map[string]string{
+			"backend": "API",
+		},
+	}
+
+	assert.Equal(t, "API", project.Normalize("BackEnd", config))
+}
+
+func TestLowercaseAliases(t *testing.T) {
+	aliases, err := project.LowercaseAliases(map[string]string{
+		"Backend":  "api",
+		" worker ": "Jobs",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"backend": "api",
+		"worker":  "Jobs",
+	}, aliases)
+}
+
+func TestLowercaseAliases_Empty(t *testing.T) {
+	aliases, err := project.LowercaseAliases(map[string]string{})
+	require.NoError(t, err)
+
+	assert.Nil(t, aliases)
+}
+
+func TestLowercaseAliases_ErrCollision(t *testing.T) {
+	_, err := project.LowercaseAliases(map[string]string{
+		"Backend": "api",
+		"backend": "server",
+	})
+	require.Error(t, err)
+
+	assert.EqualError(t, err, `project aliases "Backend" and "backend" only differ by case`)
+}
+
+func TestWithDetection_Normalize(t *testing.T) {
+	tests := map[string]struct {
+		Override string
+		Expected string
+	}{
+		"detected project normalized": {
+			Expected: "wakatime",
+		},
+		"override not normalized": {
+			Override: "Billing-CLI",
+			Expected: "Billing-CLI",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fp, tearDown := setupTestGitBasic(t)
+			defer tearDown()
+
+			opt := project.WithDetection(project.Config{
+				Override: test.Override,
+				Normalize: project.NormalizeConfig{
+					Lowercase:     true,
+					StripSuffixes: []*regexp.Regexp{regexp.MustCompile(`-cli$`)},
+				},
+			})
+
+			handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+				assert.Equal(t, heartbeat.String(test.Expected), hh[0].Project)
+				return nil, nil
+			})
+
+			_, err := handle([]heartbeat.Heartbeat{
+				{
+					EntityType: heartbeat.FileType,
+					Entity:     path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+				},
+			})
+			require.NoError(t, err)
+		})
+	}
+}
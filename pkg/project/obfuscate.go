@@ -0,0 +1,128 @@
+package project
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// nolint
+var adjectives = []string{
+	"aged", "ancient", "autumn", "billowing", "bitter", "black", "blue", "bold",
+	"broad", "broken", "calm", "cold", "cool", "crimson", "curly", "damp",
+	"dark", "dawn", "delicate", "divine", "dry", "empty", "falling", "fancy",
+	"flat", "floral", "fragrant", "frosty", "gentle", "green", "hidden", "holy",
+	"icy", "jolly", "late", "lingering", "little", "lively", "long", "lucky",
+	"misty", "morning", "muddy", "nameless", "noisy", "old", "orange", "patient",
+	"plain", "polished", "proud", "purple", "quiet", "rapid", "raspy", "red",
+	"restless", "rough", "round", "royal", "shiny", "shrill", "shy", "silent",
+	"small", "snowy", "soft", "solitary", "sparkling", "spring", "square", "steep",
+	"still", "summer", "super", "sweet", "throbbing", "tight", "tiny", "twilight",
+	"wandering", "weathered", "white", "wild", "winter", "wispy", "withered", "yellow",
+	"young",
+}
+
+// nolint
+var nouns = []string{
+	"art", "band", "bar", "base", "bird", "block", "boat", "bonus",
+	"bread", "breeze", "brook", "bush", "butterfly", "cake", "cell", "cherry",
+	"cloud", "credit", "darkness", "dawn", "dew", "disk", "dream", "dust",
+	"feather", "field", "fire", "firefly", "flower", "fog", "forest", "frog",
+	"frost", "glade", "glitter", "grass", "hall", "hat", "haze", "heart",
+	"hill", "king", "lab", "lake", "leaf", "limit", "math", "meadow",
+	"mode", "moon", "morning", "mountain", "mouse", "mud", "night", "paper",
+	"pine", "poetry", "pond", "queen", "rain", "recipe", "resonance", "rice",
+	"river", "salad", "scene", "sea", "shadow", "shape", "silence", "sky",
+	"smoke", "snow", "snowflake", "sound", "star", "sun", "sunset", "surf",
+	"term", "thunder", "tooth", "tree", "truth", "union", "unit", "violet",
+	"voice", "water", "waterfall", "wave", "wildflower", "wind", "wood",
+}
+
+// obfuscate returns a random name for the project at folder and persists it
+// in a .wakatime-project file, so that the same name is reused from then on.
+// If the file is not written, because of dryRun or for example because the
+// folder is read-only, a name derived from the folder path or the project
+// name is returned, which is stable as well but not persisted.
+func obfuscate(folder, project string, key []byte, dryRun bool) string {
+	if folder == "" {
+		return stableProjectName(project, key)
+	}
+
+	fp := path.Join(folder, defaultProjectFile)
+
+	if lines, err := readFile(fp); err == nil && len(lines) > 0 && strings.TrimSpace(lines[0]) != "" {
+		return strings.TrimSpace(lines[0])
+	}
+
+	if dryRun || fileExists(fp) {
+		return stableProjectName(folder, key)
+	}
+
+	// nolint:gosec
+	name := GenerateProjectName(rand.New(rand.NewSource(time.Now().UnixNano())))
+
+	err := ioutil.WriteFile(fp, []byte(name+"\n"), 0644) // nolint:gosec
+	if err == nil {
+		jww.DEBUG.Printf("created project file %q with obfuscated project name", fp)
+		return name
+	}
+
+	jww.WARN.Printf("failed to write project file %q: %s", fp, err)
+
+	return stableProjectName(folder, key)
+}
+
+// stableProjectName generates a project name from the word list, which is
+// always the same for the same seed and key. With key, the name is derived via
+// HMAC, so that it cannot be reversed by guessing seeds without knowing the
+// key. Without key, the name is derived from the sha256 hash of the seed.
+func stableProjectName(seed string, key []byte) string {
+	var sum []byte
+
+	if len(key) == 0 {
+		jww.DEBUG.Println("missing hide_file_names_key. deriving obfuscated project name from unkeyed hash")
+
+		hash := sha256.Sum256([]byte(seed))
+		sum = hash[:]
+	} else {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(seed))
+		sum = mac.Sum(nil)
+	}
+
+	// nolint:gosec
+	return GenerateProjectName(rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(sum[:8])))))
+}
+
+// GenerateProjectName generates a random project name from a word list, like
+// "Silent Meadow 42".
+func GenerateProjectName(r *rand.Rand) string {
+	return fmt.Sprintf(
+		"%s %s %d",
+		strings.Title(adjectives[r.Intn(len(adjectives))]),
+		strings.Title(nouns[r.Intn(len(nouns))]),
+		r.Intn(100)+1,
+	)
+}
+
+// shouldObfuscate checks if the entity or the detected project name match
+// any of the patterns.
+func shouldObfuscate(entity, project string, patterns []matcher.Matcher) bool {
+	for _, p := range patterns {
+		if p.MatchString(entity) || p.MatchString(project) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,11 +1,15 @@
 package project
 
 import (
+	"fmt"
+	"path"
 	"regexp"
 
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 
 	jww "github.com/spf13/jwalterweatherman"
+	"github.com/yookoala/realpath"
 )
 
 // Detecter is a common interface for project.
@@ -18,6 +22,11 @@ type Detecter interface {
 type Result struct {
 	Project string
 	Branch  string
+	// Folder is the root directory of the detected project.
+	Folder string
+	// Hidden is set, if the project matches ObfuscatePatterns. The project
+	// name then is the obfuscated name, but metadata must still be hidden.
+	Hidden bool
 }
 
 // Config contains project detection configurations.
@@ -29,9 +38,26 @@ type Config struct {
 	// Patterns contains the overridden project name per path.
 	MapPatterns []MapPattern
 	// SubmodulePatterns contains the paths to validate for submodules.
-	SubmodulePatterns []*regexp.Regexp
-	// ShouldObfuscateProject if true will take Alternative string, otherwise will be ignored.
-	ShouldObfuscateProject bool
+	SubmodulePatterns []matcher.Matcher
+	// ObfuscatePatterns are matched against the entity and the project name detected
+	// from revision control. If matching, the project name is replaced by a random name,
+	// which is persisted in a .wakatime-project file in the repository root.
+	ObfuscatePatterns []matcher.Matcher
+	// ObfuscateKey is the key to derive obfuscated project names from, if they
+	// cannot be persisted.
+	ObfuscateKey []byte
+	// DryRun is set, if detection must not write files.
+	DryRun bool
+	// Cache is an optional persistent cache of detection results.
+	Cache *Cache
+	// BranchMapPatterns contains the [branchmap] patterns to rename detected branches.
+	BranchMapPatterns []MapPattern
+	// IssueKeyPattern optionally extracts an issue key from the detected branch name.
+	IssueKeyPattern *regexp.Regexp
+	// Normalize defines how detected project names are normalized. Override is never normalized.
+	Normalize NormalizeConfig
+	// Trace optionally records which detector set project and branch.
+	Trace *heartbeat.Trace
 }
 
 // MapPattern contains [projectmap] data.
@@ -40,6 +66,12 @@ type MapPattern struct {
 	Name string
 	// Regex is the regular expression for a specific path.
 	Regex *regexp.Regexp
+	// Branch is an optional branch name, which can use capture groups like Name.
+	Branch string
+	// Category is an optional category, which replaces the default coding category.
+	Category *heartbeat.Category
+	// Rule is the original config entry, used for debugging output.
+	Rule string
 }
 
 // WithDetection finds the current project and branch.
@@ -54,24 +86,40 @@ func WithDetection(c Config) heartbeat.HandleOption {
 					project := firstNonEmptyString(c.Override, c.Alternative)
 					hh[n].Project = &project
 
+					c.Trace.Add("project", "%s: project %q set from arguments for non-file entity", h.Entity, project)
+
 					continue
 				}
 
-				project, branch := Detect(h.Entity, c.MapPatterns)
+				result, category := detect(h.Entity, c)
+
+				if category != nil && h.Category == heartbeat.CodingCategory {
+					hh[n].Category = *category
+
+					c.Trace.Add("project", "%s: category %q set by [projectmap] rule", h.Entity, category)
+				}
+
+				if issueKey, ok := ExtractIssueKey(result.Branch, c.IssueKeyPattern); ok {
+					hh[n].IssueKey = &issueKey
 
-				if project == "" {
-					project = c.Override
+					c.Trace.Add("project", "%s: issue key %q extracted from branch", h.Entity, issueKey)
 				}
 
-				if project == "" || branch == "" {
-					project, branch = DetectWithRevControl(h.Entity, c.SubmodulePatterns, project, branch)
-					if c.ShouldObfuscateProject {
-						project = ""
-					}
+				branch := MapBranch(result.Branch, c.BranchMapPatterns)
+				if branch != result.Branch {
+					c.Trace.Add("project", "%s: branch %q renamed to %q by [branchmap] rule", h.Entity, result.Branch, branch)
 				}
 
 				hh[n].Branch = &branch
-				hh[n].Project = &project
+				hh[n].Project = &result.Project
+				hh[n].ProjectPath = result.Folder
+				hh[n].ProjectHidden = result.Hidden
+			}
+
+			if c.Cache != nil && !c.DryRun {
+				if err := c.Cache.Save(); err != nil {
+					jww.WARN.Printf("failed to save project detection cache: %s", err)
+				}
 			}
 
 			return next(hh)
@@ -79,6 +127,138 @@ func WithDetection(c Config) heartbeat.HandleOption {
 	}
 }
 
+// detect finds project, branch, project root folder and optionally the category
+// of a file entity. Project file and revision control detection results are
+// looked up per directory from the cache, if configured. Map patterns are
+// matched against the full entity path and are therefore never cached.
+func detect(entity string, c Config) (Result, *heartbeat.Category) {
+	var (
+		project, branch, folder string
+		projectSource           string
+		branchSource            string
+		category                *heartbeat.Category
+		hidden                  bool
+	)
+
+	file, revControl := detectDirectory(entity, c)
+
+	if file != nil {
+		folder = file.Folder
+	} else if revControl != nil {
+		folder = revControl.Folder
+	}
+
+	if file != nil {
+		project, branch = Normalize(file.Project, c.Normalize), file.Branch
+		projectSource, branchSource = File{}.String(), File{}.String()
+	} else if match, ok := detectMap(entity, c.MapPatterns); ok {
+		project, branch = Normalize(match.Project, c.Normalize), match.Branch
+		category = match.Pattern.Category
+		projectSource = fmt.Sprintf("[projectmap] rule %q", match.Pattern.Rule)
+		branchSource = projectSource
+	}
+
+	if project == "" && c.Override != "" {
+		project, projectSource = c.Override, "project argument"
+	}
+
+	// an obfuscated name read from the project file no longer matches the patterns
+	if revControl != nil && shouldObfuscate(entity, revControl.Project, c.ObfuscatePatterns) {
+		hidden = true
+	}
+
+	if project == "" || branch == "" {
+		if revControl != nil {
+			if project == "" && hidden {
+				project = obfuscate(revControl.Folder, revControl.Project, c.ObfuscateKey, c.DryRun)
+				projectSource = "obfuscation"
+			}
+
+			if project == "" {
+				project, projectSource = Normalize(revControl.Project, c.Normalize), "revision control"
+			}
+
+			if branch == "" {
+				branch, branchSource = revControl.Branch, "revision control"
+			}
+		} else {
+			project, branch = "", ""
+			projectSource, branchSource = "", ""
+		}
+	}
+
+	if projectSource != "" {
+		c.Trace.Add("project", "%s: project %q set by %s", entity, project, projectSource)
+	} else {
+		c.Trace.Add("project", "%s: no project detected", entity)
+	}
+
+	if branch != "" {
+		c.Trace.Add("project", "%s: branch %q set by %s", entity, branch, branchSource)
+	}
+
+	return Result{
+		Project: project,
+		Branch:  branch,
+		Folder:  folder,
+		Hidden:  hidden,
+	}, category
+}
+
+// detectMap matches an entity against the map patterns and logs errors.
+func detectMap(entity string, patterns []MapPattern) (MapMatch, bool) {
+	if len(patterns) == 0 {
+		return MapMatch{}, false
+	}
+
+	match, ok, err := MatchMap(entity, patterns)
+	if err != nil {
+		jww.ERROR.Printf("unexpected error occurred at %q: %s", Map{}.String(), err)
+		return MapMatch{}, false
+	}
+
+	return match, ok
+}
+
+// detectDirectory runs project file and revision control detection for the
+// directory of an entity, consulting the cache first. Revision control
+// detection is skipped, if the project file already provides project and branch.
+func detectDirectory(entity string, c Config) (file, revControl *Result) {
+	var dir string
+
+	if c.Cache != nil {
+		fp, err := realpath.Realpath(entity)
+		if err == nil {
+			dir = path.Dir(fp)
+		}
+	}
+
+	if dir != "" {
+		if entry, ok := c.Cache.Get(dir); ok {
+			jww.DEBUG.Printf("project cache hit for %q", dir)
+			c.Trace.Add("project", "%s: using cached detection results for %q", entity, dir)
+
+			return entry.File, entry.RevControl
+		}
+	}
+
+	if result, ok := detectWith(File{Filepath: entity}, c.Trace); ok {
+		file = &result
+	}
+
+	if file == nil || file.Project == "" || file.Branch == "" {
+		if result, ok := detectWithRevControl(entity, c.SubmodulePatterns, c.Trace); ok {
+			revControl = &result
+		}
+	}
+
+	if dir != "" {
+		c.Cache.Set(dir, file, revControl)
+	}
+
+	return file, revControl
+}
+
 // Detect finds the current project and branch from config plugins.
 func Detect(entity string, patterns []MapPattern) (project, branch string) {
 	var configPlugins []Detecter = []Detecter{
@@ -92,11 +272,7 @@ func Detect(entity string, patterns []MapPattern) (project, branch string) {
 	}
 
 	for _, p := range configPlugins {
-		result, detected, err := p.Detect()
-		if err != nil {
-			jww.ERROR.Printf("unexpected error occurred at %q: %s", p.String(), err)
-			continue
-		} else if detected {
+		if result, detected := detectWith(p, nil); detected {
 			return result.Project, result.Branch
 		}
 	}
@@ -105,8 +281,19 @@ func Detect(entity string, patterns []MapPattern) (project, branch string) {
 }
 
 // DetectWithRevControl finds the current project and branch from rev control.
-func DetectWithRevControl(entity string, submodulePatterns []*regexp.Regexp,
+func DetectWithRevControl(entity string, submodulePatterns []matcher.Matcher,
 	project string, branch string) (string, string) {
+	result, detected := detectWithRevControl(entity, submodulePatterns, nil)
+	if !detected {
+		return "", ""
+	}
+
+	return firstNonEmptyString(project, result.Project),
+		firstNonEmptyString(branch, result.Branch)
+}
+
+// detectWithRevControl runs the revision control plugins and returns the first result.
+func detectWithRevControl(entity string, submodulePatterns []matcher.Matcher, trace *heartbeat.Trace) (Result, bool) {
 	var revControlPlugins []Detecter = []Detecter{
 		Git{
 			Filepath:          entity,
@@ -121,17 +308,28 @@ func DetectWithRevControl(entity string, submodulePatterns []*regexp.Regexp,
 	}
 
 	for _, p := range revControlPlugins {
-		result, detected, err := p.Detect()
-		if err != nil {
-			jww.ERROR.Printf("unexpected error occurred at %q: %s", p.String(), err)
-			continue
-		} else if detected {
-			return firstNonEmptyString(project, result.Project),
-				firstNonEmptyString(branch, result.Branch)
+		if result, detected := detectWith(p, trace); detected {
+			return result, true
 		}
 	}
 
-	return "", ""
+	return Result{}, false
+}
+
+// detectWith runs a single detecter, logs errors and traces results.
+func detectWith(d Detecter, trace *heartbeat.Trace) (Result, bool) {
+	result, detected, err := d.Detect()
+	if err != nil {
+		jww.ERROR.Printf("unexpected error occurred at %q: %s", d.String(), err)
+		return Result{}, false
+	}
+
+	if detected {
+		trace.Add("project", "%s detected project %q and branch %q in %q",
+			d.String(), result.Project, result.Branch, result.Folder)
+	}
+
+	return result, detected
 }
 
 // firstNonEmptyString accepts multiple values and return the first non empty string value.
@@ -2,6 +2,7 @@ package project_test
 
 import (
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
 	"github.com/wakatime/wakatime-cli/pkg/project"
 
 	"github.com/stretchr/testify/assert"
@@ -80,10 +82,11 @@ func TestDetectWithDetection_OverrideTakesPrecedence(t *testing.T) {
 	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
 		assert.Equal(t, []heartbeat.Heartbeat{
 			{
-				Entity:     path.Join(fp, "wakatime-cli/src/pkg/file.go"),
-				EntityType: heartbeat.FileType,
-				Project:    heartbeat.String("billing"),
-				Branch:     heartbeat.String("master"),
+				Entity:      path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+				EntityType:  heartbeat.FileType,
+				Project:     heartbeat.String("billing"),
+				Branch:      heartbeat.String("master"),
+				ProjectPath: path.Join(fp, "wakatime-cli"),
 			},
 		}, hh)
 
@@ -103,27 +106,194 @@ func TestDetectWithDetection_ObfuscateProject(t *testing.T) {
 	fp, tearDown := setupTestGitBasic(t)
 	defer tearDown()
 
-	opt := project.WithDetection(project.Config{
-		ShouldObfuscateProject: true,
-	})
+	detect := func() heartbeat.Heartbeat {
+		var result heartbeat.Heartbeat
 
-	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
-		assert.Equal(t, []heartbeat.Heartbeat{
+		opt := project.WithDetection(project.Config{
+			ObfuscatePatterns: []matcher.Matcher{regexp.MustCompile("wakatime-cli")},
+		})
+
+		handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			result = hh[0]
+			return nil, nil
+		})
+
+		_, err := handle([]heartbeat.Heartbeat{
 			{
+				EntityType: heartbeat.FileType,
 				Entity:     path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+			},
+		})
+		require.NoError(t, err)
+
+		return result
+	}
+
+	h := detect()
+	require.NotNil(t, h.Project)
+	assert.NotEmpty(t, *h.Project)
+	assert.NotEqual(t, "wakatime-cli", *h.Project)
+	assert.Equal(t, heartbeat.String("master"), h.Branch)
+	assert.True(t, h.ProjectHidden)
+
+	data, err := ioutil.ReadFile(path.Join(fp, "wakatime-cli/.wakatime-project"))
+	require.NoError(t, err)
+
+	assert.Equal(t, *h.Project+"\n", string(data))
+
+	// obfuscated name is reused and still hidden, though it no longer matches the pattern
+	reused := detect()
+
+	assert.Equal(t, h.Project, reused.Project)
+	assert.True(t, reused.ProjectHidden)
+}
+
+func TestDetectWithDetection_ObfuscateProject_ReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Skipping because root can write to read-only directories.")
+	}
+
+	fp, tearDown := setupTestGitBasic(t)
+	defer tearDown()
+
+	root := path.Join(fp, "wakatime-cli")
+
+	err := os.Chmod(root, 0500)
+	require.NoError(t, err)
+
+	defer os.Chmod(root, 0700) // nolint:errcheck
+
+	detect := func() string {
+		var result string
+
+		opt := project.WithDetection(project.Config{
+			ObfuscateKey:      []byte("secret"),
+			ObfuscatePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		})
+
+		handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			result = *hh[0].Project
+			return nil, nil
+		})
+
+		_, err := handle([]heartbeat.Heartbeat{
+			{
 				EntityType: heartbeat.FileType,
-				Project:    heartbeat.String(""),
-				Branch:     heartbeat.String("master"),
+				Entity:     path.Join(root, "src/pkg/file.go"),
 			},
-		}, hh)
+		})
+		require.NoError(t, err)
+
+		return result
+	}
+
+	project := detect()
+	assert.NotEmpty(t, project)
+	assert.NotEqual(t, "wakatime-cli", project)
+	assert.Equal(t, project, detect())
+
+	_, err = os.Stat(path.Join(root, ".wakatime-project"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDetectWithDetection_ObfuscateProject_DryRun(t *testing.T) {
+	fp, tearDown := setupTestGitBasic(t)
+	defer tearDown()
+
+	root := path.Join(fp, "wakatime-cli")
+
+	detect := func(key []byte) string {
+		var result string
+
+		opt := project.WithDetection(project.Config{
+			DryRun:            true,
+			ObfuscateKey:      key,
+			ObfuscatePatterns: []matcher.Matcher{regexp.MustCompile(".*")},
+		})
+
+		handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			result = *hh[0].Project
+			return nil, nil
+		})
+
+		_, err := handle([]heartbeat.Heartbeat{
+			{
+				EntityType: heartbeat.FileType,
+				Entity:     path.Join(root, "src/pkg/file.go"),
+			},
+		})
+		require.NoError(t, err)
+
+		return result
+	}
+
+	project := detect([]byte("secret"))
+	assert.NotEmpty(t, project)
+	assert.NotEqual(t, "wakatime-cli", project)
+	assert.Equal(t, project, detect([]byte("secret")))
+
+	// the name cannot be derived from the folder without the key
+	assert.NotEqual(t, project, detect([]byte("other")))
+
+	// without key, the name is still stable
+	assert.Equal(t, detect(nil), detect(nil))
+
+	_, err := os.Stat(path.Join(root, ".wakatime-project"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGenerateProjectName(t *testing.T) {
+	name := project.GenerateProjectName(rand.New(rand.NewSource(1))) // nolint:gosec
+
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z][a-z]+ [A-Z][a-z]+ \d{1,3}$`), name)
+	assert.Equal(t, name, project.GenerateProjectName(rand.New(rand.NewSource(1)))) // nolint:gosec
+}
+
+func TestWithDetection_MapCategory(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "wakatime")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	tmpFile, err := ioutil.TempFile(tmpDir, "waka-billing")
+	require.NoError(t, err)
+
+	category := heartbeat.CodeReviewingCategory
+
+	opt := project.WithDetection(project.Config{
+		MapPatterns: []project.MapPattern{
+			{
+				Name:     "my-{0}-project",
+				Regex:    regexp.MustCompile(filepath.Join(tmpDir, "waka-([a-z]+)")),
+				Branch:   "review-{0}",
+				Category: &category,
+			},
+		},
+	})
+
+	handle := opt(func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+		require.Len(t, hh, 2)
+
+		assert.Equal(t, heartbeat.String("my-billing-project"), hh[0].Project)
+		assert.Equal(t, heartbeat.String("review-billing"), hh[0].Branch)
+
+		// only the default category is replaced
+		assert.Equal(t, heartbeat.CodeReviewingCategory, hh[0].Category)
+		assert.Equal(t, heartbeat.DebuggingCategory, hh[1].Category)
 
 		return nil, nil
 	})
 
-	_, err := handle([]heartbeat.Heartbeat{
+	_, err = handle([]heartbeat.Heartbeat{
 		{
+			Category:   heartbeat.CodingCategory,
 			EntityType: heartbeat.FileType,
-			Entity:     path.Join(fp, "wakatime-cli/src/pkg/file.go"),
+			Entity:     tmpFile.Name(),
+		},
+		{
+			Category:   heartbeat.DebuggingCategory,
+			EntityType: heartbeat.FileType,
+			Entity:     tmpFile.Name(),
 		},
 	})
 	require.NoError(t, err)
@@ -176,7 +346,7 @@ func TestDetectWithRevControl_GitDetected(t *testing.T) {
 
 	project, branch := project.DetectWithRevControl(
 		path.Join(fp, "wakatime-cli/src/pkg/file.go"),
-		[]*regexp.Regexp{}, "", "")
+		[]matcher.Matcher{}, "", "")
 
 	assert.Equal(t, "wakatime-cli", project)
 	assert.Equal(t, "master", branch)
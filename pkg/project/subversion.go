@@ -50,6 +50,7 @@ func (s Subversion) Detect() (Result, bool, error) {
 		return Result{
 			Project: resolveSvnInfo(info, "Repository Root"),
 			Branch:  resolveSvnInfo(info, "URL"),
+			Folder:  path.Dir(svnConfigFile),
 		}, true, nil
 	}
 
@@ -0,0 +1,92 @@
+package route
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// FanOut sends every heartbeat to all destinations, e.g. to wakatime.com and
+// a self-hosted server. The first destination is the primary one.
+//
+// Each destination should queue its own failures, e.g. by wrapping its api
+// client by an offline queue. Then an outage of one destination does not
+// cause heartbeats to be sent twice to the others.
+type FanOut struct {
+	Destinations []Destination
+	// Trace optionally records the result per destination.
+	Trace *heartbeat.Trace
+}
+
+// DestinationResult contains the results of sending heartbeats to a destination.
+type DestinationResult struct {
+	Destination string
+	Results     []heartbeat.Result
+	Err         error
+}
+
+// Send sends heartbeats to all destinations. Returns the results and the error
+// of the primary destination. Failures of other destinations are only logged.
+func (f FanOut) Send(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+	if len(f.Destinations) == 0 {
+		return nil, fmt.Errorf("no destination to send heartbeat(s) to")
+	}
+
+	results := f.SendAll(hh)
+
+	for _, r := range results[1:] {
+		if r.Err != nil {
+			jww.ERROR.Printf("failed to send heartbeat(s) to %s: %s", r.Destination, r.Err)
+		}
+	}
+
+	if results[0].Err != nil {
+		return nil, fmt.Errorf("failed to send heartbeat(s) to %s: %w", results[0].Destination, results[0].Err)
+	}
+
+	return results[0].Results, nil
+}
+
+// SendAll sends heartbeats to all destinations concurrently and returns the
+// results per destination, in the order of the destinations.
+func (f FanOut) SendAll(hh []heartbeat.Heartbeat) []DestinationResult {
+	results := make([]DestinationResult, len(f.Destinations))
+
+	var wg sync.WaitGroup
+
+	for i, destination := range f.Destinations {
+		wg.Add(1)
+
+		go func(i int, destination Destination) {
+			defer wg.Done()
+
+			// every destination gets its own copy, as handles may modify the slice
+			group := make([]heartbeat.Heartbeat, len(hh))
+			copy(group, hh)
+
+			rr, err := destination.Handle(group)
+
+			results[i] = DestinationResult{
+				Destination: destination.Name,
+				Results:     rr,
+				Err:         err,
+			}
+		}(i, destination)
+	}
+
+	wg.Wait()
+
+	for _, r := range results {
+		if r.Err != nil {
+			f.Trace.Add("fanout", "failed to send %d heartbeat(s) to %s: %s", len(hh), r.Destination, r.Err)
+			continue
+		}
+
+		f.Trace.Add("fanout", "sent %d heartbeat(s) to %s", len(hh), r.Destination)
+	}
+
+	return results
+}
@@ -0,0 +1,103 @@
+package route_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanOut_Send(t *testing.T) {
+	var primaryHeartbeats, mirrorHeartbeats []heartbeat.Heartbeat
+
+	fanOut := route.FanOut{
+		Destinations: []route.Destination{
+			testDestination("primary", &primaryHeartbeats, nil),
+			testDestination("mirror", &mirrorHeartbeats, nil),
+		},
+	}
+
+	hh := []heartbeat.Heartbeat{{Entity: "/tmp/a.go"}, {Entity: "/tmp/b.go"}}
+
+	results, err := fanOut.Send(hh)
+	require.NoError(t, err)
+
+	assert.Equal(t, hh, primaryHeartbeats)
+	assert.Equal(t, hh, mirrorHeartbeats)
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "/tmp/a.go", results[0].Heartbeat.Entity)
+	assert.Equal(t, "/tmp/b.go", results[1].Heartbeat.Entity)
+}
+
+func TestFanOut_Send_MirrorFails(t *testing.T) {
+	var primaryHeartbeats []heartbeat.Heartbeat
+
+	trace := &heartbeat.Trace{}
+
+	fanOut := route.FanOut{
+		Destinations: []route.Destination{
+			testDestination("primary", &primaryHeartbeats, nil),
+			testDestination("mirror", nil, errors.New("unavailable")),
+		},
+		Trace: trace,
+	}
+
+	results, err := fanOut.Send([]heartbeat.Heartbeat{{Entity: "/tmp/a.go"}})
+	require.NoError(t, err)
+
+	assert.Len(t, results, 1)
+	assert.Equal(t, []heartbeat.Heartbeat{{Entity: "/tmp/a.go"}}, primaryHeartbeats)
+
+	assert.Equal(t, []heartbeat.TraceEntry{
+		{Stage: "fanout", Message: "sent 1 heartbeat(s) to primary"},
+		{Stage: "fanout", Message: "failed to send 1 heartbeat(s) to mirror: unavailable"},
+	}, trace.Entries)
+}
+
+func TestFanOut_Send_PrimaryFails(t *testing.T) {
+	var mirrorHeartbeats []heartbeat.Heartbeat
+
+	fanOut := route.FanOut{
+		Destinations: []route.Destination{
+			testDestination("primary", nil, errors.New("unavailable")),
+			testDestination("mirror", &mirrorHeartbeats, nil),
+		},
+	}
+
+	_, err := fanOut.Send([]heartbeat.Heartbeat{{Entity: "/tmp/a.go"}})
+	require.Error(t, err)
+
+	assert.EqualError(t, err, "failed to send heartbeat(s) to primary: unavailable")
+
+	// the mirror still received the heartbeat
+	assert.Equal(t, []heartbeat.Heartbeat{{Entity: "/tmp/a.go"}}, mirrorHeartbeats)
+}
+
+func TestFanOut_SendAll(t *testing.T) {
+	fanOut := route.FanOut{
+		Destinations: []route.Destination{
+			testDestination("primary", nil, nil),
+			testDestination("mirror", nil, errors.New("unavailable")),
+		},
+	}
+
+	results := fanOut.SendAll([]heartbeat.Heartbeat{{Entity: "/tmp/a.go"}})
+
+	assert.Equal(t, []route.DestinationResult{
+		{
+			Destination: "primary",
+			Results: []heartbeat.Result{
+				{Status: 201, Heartbeat: heartbeat.Heartbeat{Entity: "/tmp/a.go"}},
+			},
+		},
+		{
+			Destination: "mirror",
+			Err:         errors.New("unavailable"),
+		},
+	}, results)
+}
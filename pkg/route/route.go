@@ -0,0 +1,146 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/matcher"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Destination is an api server and account heartbeats are sent to.
+type Destination struct {
+	// Name identifies the destination in logs. Destinations are grouped by name,
+	// so it must be unique per destination.
+	Name string
+	// Handle sends heartbeats, e.g. an api client wrapped by an offline queue.
+	Handle heartbeat.Handle
+}
+
+// Route sends heartbeats with a project or entity matching Pattern to Destination.
+type Route struct {
+	Pattern     matcher.Matcher
+	Destination Destination
+	// Rule is the original config entry, used for debugging output.
+	Rule string
+}
+
+// Matches checks the route pattern against the project and the entity of a heartbeat.
+func (r Route) Matches(h heartbeat.Heartbeat) bool {
+	if h.Project != nil && *h.Project != "" && r.Pattern.MatchString(*h.Project) {
+		return true
+	}
+
+	return r.Pattern.MatchString(h.Entity)
+}
+
+// Sender groups heartbeats by the first matching route and sends each group
+// to its destination. Heartbeats not matching any route are sent to Default.
+// It needs to run after project detection, as routes match the project.
+type Sender struct {
+	Routes  []Route
+	Default Destination
+	// APIKeys contains destinations for heartbeats with an api key set, like
+	// heartbeats outside of the schedule. They take precedence over Routes.
+	// Destinations authenticate with their own api key, so heartbeats with an
+	// api key set need a destination here to be sent with it.
+	APIKeys map[string]Destination
+	// Trace optionally records the destination of each heartbeat.
+	Trace *heartbeat.Trace
+}
+
+// Send sends heartbeats to their destinations. A failing destination does not
+// prevent sending to the others. Results are returned in the order of the
+// passed in heartbeats, if every destination returns one result per heartbeat.
+func (s Sender) Send(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+	var (
+		destinations []Destination
+		indexes      = make(map[string][]int)
+	)
+
+	for i, h := range hh {
+		destination := s.route(h)
+
+		if _, ok := indexes[destination.Name]; !ok {
+			destinations = append(destinations, destination)
+		}
+
+		indexes[destination.Name] = append(indexes[destination.Name], i)
+	}
+
+	var (
+		results = make([]heartbeat.Result, len(hh))
+		ordered = true
+		all     []heartbeat.Result
+		failed  []string
+		sendErr error
+	)
+
+	for _, destination := range destinations {
+		group := make([]heartbeat.Heartbeat, len(indexes[destination.Name]))
+		for n, i := range indexes[destination.Name] {
+			group[n] = hh[i]
+		}
+
+		rr, err := destination.Handle(group)
+		if err != nil {
+			jww.ERROR.Printf("failed to send heartbeat(s) to %s: %s", destination.Name, err)
+
+			if sendErr == nil {
+				sendErr = err
+			}
+
+			failed = append(failed, destination.Name)
+
+			ordered = false
+
+			continue
+		}
+
+		if len(rr) != len(group) {
+			ordered = false
+		}
+
+		for n, r := range rr {
+			if n < len(group) {
+				results[indexes[destination.Name][n]] = r
+			}
+		}
+
+		all = append(all, rr...)
+	}
+
+	if sendErr != nil {
+		return nil, fmt.Errorf("failed to send heartbeat(s) to %s: %w", strings.Join(failed, ", "), sendErr)
+	}
+
+	if !ordered {
+		return all, nil
+	}
+
+	return results, nil
+}
+
+// route returns the destination of the api key or of the first route matching
+// the heartbeat.
+func (s Sender) route(h heartbeat.Heartbeat) Destination {
+	if destination, ok := s.APIKeys[h.APIKey]; ok && h.APIKey != "" {
+		s.Trace.Add("route", "%s: sending to %s by heartbeat api key", h.Entity, destination.Name)
+
+		return destination
+	}
+
+	for _, r := range s.Routes {
+		if r.Matches(h) {
+			s.Trace.Add("route", "%s: sending to %s by [api_routes] rule %q", h.Entity, r.Destination.Name, r.Rule)
+
+			return r.Destination
+		}
+	}
+
+	s.Trace.Add("route", "%s: sending to %s", h.Entity, s.Default.Name)
+
+	return s.Default
+}
@@ -0,0 +1,212 @@
+package route_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+	"github.com/wakatime/wakatime-cli/pkg/route"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute_Matches(t *testing.T) {
+	tests := map[string]struct {
+		Heartbeat heartbeat.Heartbeat
+		Expected  bool
+	}{
+		"project matches": {
+			Heartbeat: heartbeat.Heartbeat{
+				Entity:  "/home/user/main.go",
+				Project: heartbeat.String("client-project"),
+			},
+			Expected: true,
+		},
+		"entity matches": {
+			Heartbeat: heartbeat.Heartbeat{
+				Entity: "/home/user/client/main.go",
+			},
+			Expected: true,
+		},
+		"no match": {
+			Heartbeat: heartbeat.Heartbeat{
+				Entity:  "/home/user/private/main.go",
+				Project: heartbeat.String("private"),
+			},
+			Expected: false,
+		},
+	}
+
+	r := route.Route{
+		Pattern: regexp.MustCompile("client"),
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, r.Matches(test.Heartbeat))
+		})
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	var clientHeartbeats, defaultHeartbeats []heartbeat.Heartbeat
+
+	sender := route.Sender{
+		Routes: []route.Route{
+			{
+				Pattern:     regexp.MustCompile("^client"),
+				Destination: testDestination("client", &clientHeartbeats, nil),
+				Rule:        "^client = key",
+			},
+		},
+		Default: testDestination("default", &defaultHeartbeats, nil),
+	}
+
+	results, err := sender.Send([]heartbeat.Heartbeat{
+		{Entity: "/tmp/a.go", Project: heartbeat.String("private")},
+		{Entity: "/tmp/b.go", Project: heartbeat.String("client-project")},
+		{Entity: "/tmp/c.go"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []heartbeat.Heartbeat{
+		{Entity: "/tmp/b.go", Project: heartbeat.String("client-project")},
+	}, clientHeartbeats)
+	assert.Equal(t, []heartbeat.Heartbeat{
+		{Entity: "/tmp/a.go", Project: heartbeat.String("private")},
+		{Entity: "/tmp/c.go"},
+	}, defaultHeartbeats)
+
+	// results keep the order of the heartbeats
+	require.Len(t, results, 3)
+	assert.Equal(t, "/tmp/a.go", results[0].Heartbeat.Entity)
+	assert.Equal(t, "/tmp/b.go", results[1].Heartbeat.Entity)
+	assert.Equal(t, "/tmp/c.go", results[2].Heartbeat.Entity)
+}
+
+func TestSender_Send_APIKeys(t *testing.T) {
+	var clientHeartbeats, defaultHeartbeats, outsideHeartbeats []heartbeat.Heartbeat
+
+	trace := &heartbeat.Trace{}
+
+	sender := route.Sender{
+		Routes: []route.Route{
+			{
+				Pattern:     regexp.MustCompile("^client"),
+				Destination: testDestination("client", &clientHeartbeats, nil),
+				Rule:        "^client = key",
+			},
+		},
+		Default: testDestination("default", &defaultHeartbeats, nil),
+		APIKeys: map[string]route.Destination{
+			"00000000-0000-4000-8000-000000000001": testDestination("outside", &outsideHeartbeats, nil),
+		},
+		Trace: trace,
+	}
+
+	hh := []heartbeat.Heartbeat{
+		{
+			Entity:  "/tmp/a.go",
+			Project: heartbeat.String("client-project"),
+			APIKey:  "00000000-0000-4000-8000-000000000001",
+		},
+		{Entity: "/tmp/b.go", Project: heartbeat.String("client-project")},
+		{Entity: "/tmp/c.go", APIKey: "00000000-0000-4000-8000-000000000002"},
+	}
+
+	_, err := sender.Send(hh)
+	require.NoError(t, err)
+
+	assert.Equal(t, []heartbeat.Heartbeat{
+		{
+			Entity:  "/tmp/a.go",
+			Project: heartbeat.String("client-project"),
+			APIKey:  "00000000-0000-4000-8000-000000000001",
+		},
+	}, outsideHeartbeats)
+	assert.Equal(t, []heartbeat.Heartbeat{
+		{Entity: "/tmp/b.go", Project: heartbeat.String("client-project")},
+	}, clientHeartbeats)
+	assert.Equal(t, []heartbeat.Heartbeat{
+		{Entity: "/tmp/c.go", APIKey: "00000000-0000-4000-8000-000000000002"},
+	}, defaultHeartbeats)
+
+	assert.Contains(t, trace.Entries, heartbeat.TraceEntry{
+		Stage:   "route",
+		Message: "/tmp/a.go: sending to outside by heartbeat api key",
+	})
+}
+
+func TestSender_Send_FailingDestination(t *testing.T) {
+	var defaultHeartbeats []heartbeat.Heartbeat
+
+	sender := route.Sender{
+		Routes: []route.Route{
+			{
+				Pattern:     regexp.MustCompile("^client"),
+				Destination: testDestination("client", nil, errors.New("unavailable")),
+			},
+		},
+		Default: testDestination("default", &defaultHeartbeats, nil),
+	}
+
+	_, err := sender.Send([]heartbeat.Heartbeat{
+		{Entity: "/tmp/a.go", Project: heartbeat.String("client-project")},
+		{Entity: "/tmp/b.go"},
+	})
+	require.Error(t, err)
+
+	assert.EqualError(t, err, "failed to send heartbeat(s) to client: unavailable")
+	assert.Equal(t, []heartbeat.Heartbeat{{Entity: "/tmp/b.go"}}, defaultHeartbeats)
+}
+
+func TestSender_Send_Trace(t *testing.T) {
+	trace := &heartbeat.Trace{}
+
+	sender := route.Sender{
+		Routes: []route.Route{
+			{
+				Pattern:     regexp.MustCompile("^client"),
+				Destination: testDestination("client", nil, nil),
+				Rule:        "^client = key",
+			},
+		},
+		Default: testDestination("default", nil, nil),
+		Trace:   trace,
+	}
+
+	_, err := sender.Send([]heartbeat.Heartbeat{
+		{Entity: "/tmp/a.go", Project: heartbeat.String("client-project")},
+		{Entity: "/tmp/b.go"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []heartbeat.TraceEntry{
+		{Stage: "route", Message: `/tmp/a.go: sending to client by [api_routes] rule "^client = key"`},
+		{Stage: "route", Message: "/tmp/b.go: sending to default"},
+	}, trace.Entries)
+}
+
+func testDestination(name string, sent *[]heartbeat.Heartbeat, err error) route.Destination {
+	return route.Destination{
+		Name: name,
+		Handle: func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			if err != nil {
+				return nil, err
+			}
+
+			if sent != nil {
+				*sent = append(*sent, hh...)
+			}
+
+			var results []heartbeat.Result
+			for _, h := range hh {
+				results = append(results, heartbeat.Result{Status: 201, Heartbeat: h})
+			}
+
+			return results, nil
+		},
+	}
+}
@@ -0,0 +1,332 @@
+package secret
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/wakatime/wakatime-cli/pkg/heartbeat"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Redacted replaces detected secrets.
+const Redacted = "REDACTED"
+
+const (
+	// minTokenLength is the minimum length of a token to check its entropy.
+	minTokenLength = 20
+	// minTokenEntropy is the minimum shannon entropy in bits per character of a
+	// token to be considered a secret. Mixed case alphanumeric random strings
+	// are close to 5.9, file names and identifiers usually are below 4.
+	minTokenEntropy = 4.0
+	// minWordLength is the minimum average length of the segments of a token,
+	// like "User" and "Service" in "UserService2Impl", to be considered an
+	// identifier instead of a secret. Random strings switch between upper case,
+	// lower case and digits every one or two characters.
+	minWordLength = 3
+)
+
+// Action defines what happens to a heartbeat containing a secret.
+type Action int
+
+const (
+	// ActionRedact replaces secrets by "REDACTED".
+	ActionRedact Action = iota
+	// ActionDrop skips the heartbeat.
+	ActionDrop
+	// ActionOff disables secret scanning.
+	ActionOff
+)
+
+const (
+	actionRedactString = "redact"
+	actionDropString   = "drop"
+	actionOffString    = "off"
+)
+
+// ParseAction parses an action from a string. An empty string is ActionRedact.
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "", actionRedactString:
+		return ActionRedact, nil
+	case actionDropString:
+		return ActionDrop, nil
+	case actionOffString:
+		return ActionOff, nil
+	default:
+		return 0, fmt.Errorf("invalid secret scanning action %q. must be one of redact, drop, off", s)
+	}
+}
+
+// String implements fmt.Stringer interface.
+func (a Action) String() string {
+	switch a {
+	case ActionRedact:
+		return actionRedactString
+	case ActionDrop:
+		return actionDropString
+	case ActionOff:
+		return actionOffString
+	default:
+		return ""
+	}
+}
+
+// Config contains secret scanning configurations.
+type Config struct {
+	Action Action
+	// Entropy enables the detection of high entropy tokens in addition to
+	// known secret formats. It might also detect unusual identifiers.
+	Entropy bool
+	// Trace optionally records detected secrets, without their values.
+	Trace *heartbeat.Trace
+}
+
+// Finding is a secret detected in a heartbeat field.
+type Finding struct {
+	// Field is the json name of the heartbeat field.
+	Field string
+	// Kind is the kind of the secret, e.g. "aws access key".
+	Kind string
+}
+
+// String implements fmt.Stringer interface. Never contains the secret value.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s in %s", f.Kind, f.Field)
+}
+
+// detector finds secrets of a kind. If the regular expression has a capture
+// group, only the group is the secret.
+type detector struct {
+	Kind  string
+	Regex *regexp.Regexp
+}
+
+// nolint
+var detectors = []detector{
+	{
+		Kind:  "aws access key",
+		Regex: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Kind:  "jwt",
+		Regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`),
+	},
+	{
+		Kind:  "url credentials",
+		Regex: regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://([^/\s:@]+:[^/\s@]+)@`),
+	},
+}
+
+// nolint
+var tokenRegex = regexp.MustCompile(`[A-Za-z0-9+=_-]{20,}`)
+
+// WithScanning initializes and returns a heartbeat handle option, which
+// can be used in a heartbeat processing pipeline to redact or drop heartbeats
+// containing secrets. It should run last before sending, so that it sees all
+// values sent to the api.
+func WithScanning(config Config) heartbeat.HandleOption {
+	return func(next heartbeat.Handle) heartbeat.Handle {
+		return func(hh []heartbeat.Heartbeat) ([]heartbeat.Result, error) {
+			if config.Action == ActionOff {
+				return next(hh)
+			}
+
+			var scanned []heartbeat.Heartbeat
+
+			for _, h := range hh {
+				redacted, findings := Scan(h, config.Entropy)
+				if len(findings) == 0 {
+					scanned = append(scanned, h)
+					continue
+				}
+
+				for _, f := range findings {
+					jww.WARN.Printf("secret detected: %s. action: %s", f, config.Action)
+					config.Trace.Add("secret", "%s: %s detected. action: %s", redacted.Entity, f, config.Action)
+				}
+
+				if config.Action == ActionDrop {
+					continue
+				}
+
+				scanned = append(scanned, redacted)
+			}
+
+			if len(scanned) == 0 {
+				jww.DEBUG.Println("no heartbeat left after secret scanning. abort heartbeat handling.")
+				return []heartbeat.Result{}, nil
+			}
+
+			return next(scanned)
+		}
+	}
+}
+
+// Scan checks all string fields of a heartbeat, which are sent to the api,
+// for secrets. High entropy tokens are only detected, if detectEntropy is true.
+// Returns the heartbeat with all secrets replaced by "REDACTED" and the findings.
+func Scan(h heartbeat.Heartbeat, detectEntropy bool) (heartbeat.Heartbeat, []Finding) {
+	var findings []Finding
+
+	redact := func(field string, value string) string {
+		redacted, kinds := RedactString(value, detectEntropy)
+		for _, kind := range kinds {
+			findings = append(findings, Finding{Field: field, Kind: kind})
+		}
+
+		return redacted
+	}
+
+	redactPtr := func(field string, value *string) *string {
+		if value == nil {
+			return nil
+		}
+
+		return heartbeat.String(redact(field, *value))
+	}
+
+	// APIKey and ProjectPath are not sent as part of the heartbeat.
+	h.Entity = redact("entity", h.Entity)
+	h.Branch = redactPtr("branch", h.Branch)
+	h.IssueKey = redactPtr("issue_key", h.IssueKey)
+	h.Language = redactPtr("language", h.Language)
+	h.Project = redactPtr("project", h.Project)
+	h.UserAgent = redact("user_agent", h.UserAgent)
+
+	if len(h.Dependencies) > 0 {
+		deps := make([]string, len(h.Dependencies))
+		for i, dep := range h.Dependencies {
+			deps[i] = redact("dependencies", dep)
+		}
+
+		h.Dependencies = deps
+	}
+
+	return h, findings
+}
+
+// RedactString replaces all secrets in s by "REDACTED" and returns the kinds of
+// the detected secrets. High entropy tokens are only detected, if detectEntropy is true.
+func RedactString(s string, detectEntropy bool) (string, []string) {
+	var kinds []string
+
+	for _, d := range detectors {
+		if !d.Regex.MatchString(s) {
+			continue
+		}
+
+		kinds = append(kinds, d.Kind)
+
+		s = d.Regex.ReplaceAllStringFunc(s, func(match string) string {
+			sub := d.Regex.FindStringSubmatchIndex(match)
+			if len(sub) < 4 || sub[2] < 0 {
+				return Redacted
+			}
+
+			return match[:sub[2]] + Redacted + match[sub[3]:]
+		})
+	}
+
+	if !detectEntropy {
+		return s, kinds
+	}
+
+	var found bool
+
+	s = tokenRegex.ReplaceAllStringFunc(s, func(token string) string {
+		if token == Redacted || !isHighEntropy(token) {
+			return token
+		}
+
+		found = true
+
+		return Redacted
+	})
+
+	if found {
+		kinds = append(kinds, "high entropy token")
+	}
+
+	return s, kinds
+}
+
+// isHighEntropy checks if a token mixes upper case letters, lower case
+// letters and digits, has a high shannon entropy and is not an identifier.
+func isHighEntropy(token string) bool {
+	if len(token) < minTokenLength || isIdentifier(token) {
+		return false
+	}
+
+	var upper, lower, digit bool
+
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+
+	if !upper || !lower || !digit {
+		return false
+	}
+
+	return entropy(token) >= minTokenEntropy
+}
+
+// isIdentifier checks if a token consists of words, like CamelCase or kebab-case
+// names. Segments are separated by "-", "_", "+" and "=", by a lower case letter
+// followed by an upper case letter and by changes between letters and digits.
+func isIdentifier(token string) bool {
+	var segments, length int
+
+	var prev rune
+
+	for _, r := range token {
+		if r == '-' || r == '_' || r == '+' || r == '=' {
+			prev = 0
+			continue
+		}
+
+		isDigit := r >= '0' && r <= '9'
+		isUpper := r >= 'A' && r <= 'Z'
+
+		switch {
+		case prev == 0:
+			segments++
+		case isDigit != (prev >= '0' && prev <= '9'):
+			segments++
+		case isUpper && prev >= 'a' && prev <= 'z':
+			segments++
+		}
+
+		length++
+		prev = r
+	}
+
+	return segments > 0 && length >= minWordLength*segments
+}
+
+// entropy returns the shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var e float64
+
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
@@ -1,6 +1,8 @@
 package vipertools
 
 import (
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
@@ -53,3 +55,26 @@ func FirstNonEmptyString(v *viper.Viper, keys ...string) (string, bool) {
 
 	return "", false
 }
+
+// GetStringMapString returns all key/value pairs of a config section as map.
+// Viper stores ini config keys flattened as "section.key", which breaks
+// v.GetStringMapString for keys containing dots, like paths or regexes.
+// Note, that viper lowercases all keys.
+func GetStringMapString(v *viper.Viper, section string) map[string]string {
+	if v == nil {
+		return nil
+	}
+
+	prefix := strings.ToLower(section) + "."
+	values := make(map[string]string)
+
+	for _, key := range v.AllKeys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		values[strings.TrimPrefix(key, prefix)] = v.GetString(key)
+	}
+
+	return values
+}
@@ -1,6 +1,7 @@
 package vipertools_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/wakatime/wakatime-cli/pkg/vipertools"
@@ -101,3 +102,30 @@ func TestFirstNonEmptyString_NotFound(t *testing.T) {
 	_, ok := vipertools.FirstNonEmptyString(viper.New(), "key")
 	assert.False(t, ok)
 }
+
+func TestGetStringMapString(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("ini")
+
+	err := v.ReadConfig(strings.NewReader(`[projectmap]
+/home/user/projects/foo = new project name
+/home/user/projects/bar(\d+)/ = project{0}
+~/src/.*\.go = golang
+
+[settings]
+debug = true
+`))
+	require.NoError(t, err)
+
+	values := vipertools.GetStringMapString(v, "projectmap")
+	assert.Equal(t, map[string]string{
+		"/home/user/projects/foo":       "new project name",
+		`/home/user/projects/bar(\d+)/`: "project{0}",
+		`~/src/.*\.go`:                  "golang",
+	}, values)
+}
+
+func TestGetStringMapString_NilPointer(t *testing.T) {
+	values := vipertools.GetStringMapString(nil, "projectmap")
+	assert.Nil(t, values)
+}